@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"golox/debug"
+	"os"
+)
+
+// runDebug implements "golox debug". With --dap it serves the Debug Adapter
+// Protocol on standard input and output, and the client names the script
+// to run.
+func runDebug(args []string) {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	dap := flags.Bool("dap", false, "serve the Debug Adapter Protocol on standard input and output")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: golox debug script\n")
+		fmt.Fprintf(os.Stderr, "       golox debug --dap\n")
+	}
+	flags.Parse(args)
+
+	if *dap {
+		if flags.NArg() != 0 {
+			flags.Usage()
+			os.Exit(64)
+		}
+		if err := debug.ServeDAP(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(64)
+	}
+	status, err := debug.RunTerminal(flags.Arg(0), os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(status)
+}
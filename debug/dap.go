@@ -0,0 +1,404 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"golox/internal/wire"
+	"golox/lox"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The subset of the Debug Adapter Protocol that the server uses. See
+// https://microsoft.github.io/debug-adapter-protocol/specification.
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path"`
+}
+
+type dapStackFrame struct {
+	ID     int        `json:"id"`
+	Name   string     `json:"name"`
+	Source *dapSource `json:"source,omitempty"`
+	Line   int        `json:"line"`
+	Column int        `json:"column"`
+}
+
+type dapScope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type dapBreakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line"`
+}
+
+// The program runs on a single thread.
+const threadID = 1
+
+// globalsReference is the variables reference for the globals scope. The
+// references for local scopes count up from it.
+const globalsReference = 1
+
+// adapter is a Debug Adapter Protocol server. Requests are read on one
+// goroutine, and the program runs on another. While the program is
+// stopped, requests that inspect it are sent to the program's goroutine
+// to run, since the interpreter isn't safe to use from two at once.
+type adapter struct {
+	r   *bufio.Reader
+	w   io.Writer
+	mu  sync.Mutex
+	seq int
+
+	debugger *Debugger
+	program  string
+	launched bool
+	// inspect carries work to the program while it is stopped, and
+	// resume tells it how to continue.
+	inspect chan func()
+	resume  chan Action
+	stopped bool
+	// scopes maps variables references to the scopes they name, for the
+	// current stop.
+	scopes [][]lox.Variable
+	done   chan struct{}
+}
+
+// ServeDAP runs a debug adapter that reads requests from r and writes
+// responses and events to w, until the client disconnects.
+func ServeDAP(r io.Reader, w io.Writer) error {
+	a := &adapter{
+		r:       bufio.NewReader(r),
+		w:       w,
+		inspect: make(chan func()),
+		resume:  make(chan Action),
+		done:    make(chan struct{}),
+	}
+	for {
+		body, err := wire.ReadMessage(a.r)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var req dapRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		if !a.handle(&req) {
+			return nil
+		}
+	}
+}
+
+// handle handles a request, reporting whether the session continues.
+func (a *adapter) handle(req *dapRequest) bool {
+	var body any
+	var err error
+	switch req.Command {
+	case "initialize":
+		body = map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+		}
+		a.respond(req, true, "", body)
+		a.event("initialized", nil)
+		return true
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			if args.Program == "" {
+				err = fmt.Errorf("Launch needs a program")
+			} else {
+				a.launch(args.Program, args.StopOnEntry)
+			}
+		}
+	case "setBreakpoints":
+		var args struct {
+			Source      dapSource `json:"source"`
+			Breakpoints []struct {
+				Line int `json:"line"`
+			} `json:"breakpoints"`
+		}
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			// Breakpoints set while the program runs take effect before its
+			// next statement.
+			set := a.debugger != nil
+			lines := []int{}
+			breakpoints := []dapBreakpoint{}
+			for _, b := range args.Breakpoints {
+				lines = append(lines, b.Line)
+				breakpoints = append(breakpoints, dapBreakpoint{Verified: set, Line: b.Line})
+			}
+			if set {
+				a.debugger.SetBreakpoints(args.Source.Path, lines)
+			}
+			body = map[string]any{"breakpoints": breakpoints}
+		}
+	case "configurationDone":
+		if a.debugger == nil {
+			err = fmt.Errorf("Configuration done before launch")
+		} else if !a.launched {
+			a.launched = true
+			go a.run()
+		}
+	case "threads":
+		body = map[string]any{
+			"threads": []map[string]any{{"id": threadID, "name": "main"}},
+		}
+	case "stackTrace":
+		frames := []dapStackFrame{}
+		a.whileStopped(func() {
+			for i, frame := range a.debugger.Stack() {
+				name := frame.Function
+				if name == "" {
+					name = "<script>"
+				}
+				f := dapStackFrame{ID: i, Name: name, Line: frame.Span.Line, Column: frame.Span.Column}
+				if frame.Span.File != "" {
+					f.Source = &dapSource{Path: canonicalPath(frame.Span.File)}
+				}
+				frames = append(frames, f)
+			}
+		})
+		body = map[string]any{"stackFrames": frames, "totalFrames": len(frames)}
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body = map[string]any{"scopes": a.frameScopes(args.FrameID)}
+		}
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body = map[string]any{"variables": a.variables(args.VariablesReference)}
+		}
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+		}
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			var result string
+			if !a.whileStopped(func() {
+				result, err = a.debugger.Evaluate(args.FrameID, args.Expression)
+			}) {
+				err = fmt.Errorf("The program isn't stopped")
+			}
+			body = map[string]any{"result": result, "variablesReference": 0}
+		}
+	case "continue":
+		a.continueWith(Continue)
+		body = map[string]any{"allThreadsContinued": true}
+	case "next":
+		a.continueWith(StepOver)
+	case "stepIn":
+		a.continueWith(StepIn)
+	case "stepOut":
+		a.continueWith(StepOut)
+	case "disconnect", "terminate":
+		if a.launched {
+			a.debugger.Terminate()
+			a.continueWith(Terminate)
+			<-a.done
+		}
+		a.respond(req, true, "", nil)
+		// A terminate request is followed by a disconnect.
+		return req.Command == "terminate"
+	default:
+		err = fmt.Errorf("Unknown command %s", req.Command)
+	}
+	if err != nil {
+		a.respond(req, false, err.Error(), nil)
+	} else {
+		a.respond(req, true, "", body)
+	}
+	return true
+}
+
+func (a *adapter) launch(program string, stopOnEntry bool) {
+	a.program = program
+	a.debugger = New(a.stoppedAt, stopOnEntry,
+		lox.WithStdout(&outputWriter{a: a, category: "stdout"}),
+		lox.WithStderr(&outputWriter{a: a, category: "stderr"}),
+		lox.WithStdin(strings.NewReader("")))
+}
+
+// run runs the program on its own goroutine.
+func (a *adapter) run() {
+	status, err := a.debugger.Run(a.program)
+	if err != nil {
+		a.event("output", map[string]any{"category": "stderr", "output": "Error: " + err.Error() + "\n"})
+		status = 1
+	}
+	a.event("exited", map[string]any{"exitCode": status})
+	a.event("terminated", nil)
+	close(a.done)
+}
+
+// stoppedAt runs on the program's goroutine when it stops. It runs
+// inspection requests until the client says how to continue.
+func (a *adapter) stoppedAt(d *Debugger, reason string) Action {
+	a.mu.Lock()
+	// The client may have terminated the program after it last checked,
+	// in which case nothing will resume it.
+	if d.terminated.Load() {
+		a.mu.Unlock()
+		return Terminate
+	}
+	a.stopped = true
+	a.scopes = nil
+	a.mu.Unlock()
+	a.event("stopped", map[string]any{
+		"reason":            reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+	for {
+		select {
+		case f := <-a.inspect:
+			f()
+			a.inspect <- nil
+		case action := <-a.resume:
+			return action
+		}
+	}
+}
+
+// whileStopped runs f on the program's goroutine if the program is
+// stopped, or directly if it hasn't started, and reports whether it ran.
+func (a *adapter) whileStopped(f func()) bool {
+	if a.debugger == nil {
+		return false
+	}
+	if !a.launched {
+		f()
+		return true
+	}
+	if !a.isStopped() {
+		return false
+	}
+	a.inspect <- f
+	<-a.inspect
+	return true
+}
+
+func (a *adapter) isStopped() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stopped
+}
+
+func (a *adapter) continueWith(action Action) {
+	if !a.isStopped() {
+		return
+	}
+	a.mu.Lock()
+	a.stopped = false
+	a.mu.Unlock()
+	a.resume <- action
+}
+
+// frameScopes returns a scope for each environment visible in a frame,
+// innermost first, followed by the globals.
+func (a *adapter) frameScopes(frame int) []dapScope {
+	scopes := []dapScope{}
+	a.whileStopped(func() {
+		for i, scope := range a.debugger.Locals(frame) {
+			a.scopes = append(a.scopes, scope)
+			name := "Locals"
+			if i > 0 {
+				name = "Enclosing " + strconv.Itoa(i)
+			}
+			scopes = append(scopes, dapScope{Name: name, VariablesReference: globalsReference + len(a.scopes)})
+		}
+	})
+	return append(scopes, dapScope{Name: "Globals", VariablesReference: globalsReference})
+}
+
+func (a *adapter) variables(ref int) []dapVariable {
+	variables := []dapVariable{}
+	a.whileStopped(func() {
+		var scope []lox.Variable
+		if ref == globalsReference {
+			scope = a.debugger.Globals()
+		} else if i := ref - globalsReference - 1; i >= 0 && i < len(a.scopes) {
+			scope = a.scopes[i]
+		}
+		for _, v := range scope {
+			variables = append(variables, dapVariable{Name: v.Name, Value: lox.Repr(v.Value)})
+		}
+	})
+	return variables
+}
+
+func (a *adapter) respond(req *dapRequest, success bool, message string, body any) {
+	msg := map[string]any{
+		"type":        "response",
+		"request_seq": req.Seq,
+		"command":     req.Command,
+		"success":     success,
+	}
+	if message != "" {
+		msg["message"] = message
+	}
+	if body != nil {
+		msg["body"] = body
+	}
+	a.send(msg)
+}
+
+func (a *adapter) event(name string, body any) {
+	msg := map[string]any{"type": "event", "event": name}
+	if body != nil {
+		msg["body"] = body
+	}
+	a.send(msg)
+}
+
+// send writes a message. Events come from the program's goroutine, so
+// writes are serialized.
+func (a *adapter) send(msg map[string]any) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seq++
+	msg["seq"] = a.seq
+	wire.WriteMessage(a.w, msg)
+}
+
+// outputWriter sends what the program writes to the client as output
+// events.
+type outputWriter struct {
+	a        *adapter
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.a.event("output", map[string]any{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,285 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"golox/internal/wire"
+	"io"
+	"testing"
+	"time"
+)
+
+// dapMessage is a response or event from the adapter.
+type dapMessage struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// dapClient drives an adapter over pipes.
+type dapClient struct {
+	t        *testing.T
+	w        io.WriteCloser
+	messages chan dapMessage
+	seq      int
+	done     chan error
+}
+
+func newDAPClient(t *testing.T) *dapClient {
+	requests, requestWriter := io.Pipe()
+	responseReader, responses := io.Pipe()
+	c := &dapClient{
+		t:        t,
+		w:        requestWriter,
+		messages: make(chan dapMessage, 100),
+		done:     make(chan error, 1),
+	}
+	go func() {
+		c.done <- ServeDAP(requests, responses)
+		responses.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		r := bufio.NewReader(responseReader)
+		for {
+			body, err := wire.ReadMessage(r)
+			if err != nil {
+				return
+			}
+			var msg dapMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *dapClient) send(command string, arguments any) int {
+	c.t.Helper()
+	c.seq++
+	msg := map[string]any{"seq": c.seq, "type": "request", "command": command}
+	if arguments != nil {
+		msg["arguments"] = arguments
+	}
+	if err := wire.WriteMessage(c.w, msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.seq
+}
+
+// until reads messages until one matches, returning it and the output
+// events read on the way.
+func (c *dapClient) until(match func(m dapMessage) bool) (dapMessage, string) {
+	c.t.Helper()
+	var output string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m, ok := <-c.messages:
+			if !ok {
+				c.t.Fatal("adapter closed the connection")
+			}
+			if m.Type == "event" && m.Event == "output" {
+				var body struct{ Output string }
+				json.Unmarshal(m.Body, &body)
+				output += body.Output
+			}
+			if match(m) {
+				return m, output
+			}
+		case <-timeout:
+			c.t.Fatal("timed out waiting for the adapter")
+		}
+	}
+}
+
+// request sends a request and waits for its response.
+func (c *dapClient) request(command string, arguments any) dapMessage {
+	c.t.Helper()
+	seq := c.send(command, arguments)
+	m, _ := c.until(func(m dapMessage) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+	return m
+}
+
+func (c *dapClient) event(name string) (dapMessage, string) {
+	c.t.Helper()
+	return c.until(func(m dapMessage) bool {
+		return m.Type == "event" && m.Event == name
+	})
+}
+
+func decodeBody[T any](t *testing.T, m dapMessage) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(m.Body, &v); err != nil {
+		t.Fatalf("decoding %s: %v", m.Body, err)
+	}
+	return v
+}
+
+func TestDAPSession(t *testing.T) {
+	path := writeProgram(t)
+	c := newDAPClient(t)
+
+	if m := c.request("initialize", map[string]any{"adapterID": "golox"}); !m.Success {
+		t.Fatalf("initialize failed: %s", m.Message)
+	}
+	c.event("initialized")
+	c.request("launch", map[string]any{"program": path})
+	breakpoints := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []map[string]any{{"line": 3}},
+	})
+	if set := decodeBody[struct{ Breakpoints []dapBreakpoint }](t, breakpoints); len(set.Breakpoints) != 1 || !set.Breakpoints[0].Verified {
+		t.Errorf("setBreakpoints = %s", breakpoints.Body)
+	}
+	c.request("configurationDone", nil)
+
+	stopped, _ := c.event("stopped")
+	if reason := decodeBody[struct{ Reason string }](t, stopped).Reason; reason != ReasonBreakpoint {
+		t.Errorf("stopped for %q, want %q", reason, ReasonBreakpoint)
+	}
+
+	stack := decodeBody[struct{ StackFrames []dapStackFrame }](t, c.request("stackTrace", map[string]any{"threadId": threadID}))
+	if len(stack.StackFrames) != 2 || stack.StackFrames[0].Name != "add" || stack.StackFrames[0].Line != 3 ||
+		stack.StackFrames[1].Name != "<script>" || stack.StackFrames[1].Line != 8 {
+		t.Errorf("stack = %+v", stack.StackFrames)
+	}
+
+	scopes := decodeBody[struct{ Scopes []dapScope }](t, c.request("scopes", map[string]any{"frameId": 0}))
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("scopes = %+v", scopes.Scopes)
+	}
+	locals := decodeBody[struct{ Variables []dapVariable }](t, c.request("variables",
+		map[string]any{"variablesReference": scopes.Scopes[0].VariablesReference}))
+	want := []dapVariable{{Name: "a", Value: "0"}, {Name: "b", Value: "0"}, {Name: "sum", Value: "0"}}
+	if len(locals.Variables) != len(want) {
+		t.Fatalf("locals = %+v, want %+v", locals.Variables, want)
+	}
+	for i := range want {
+		if locals.Variables[i] != want[i] {
+			t.Errorf("locals = %+v, want %+v", locals.Variables, want)
+			break
+		}
+	}
+
+	evaluate := c.request("evaluate", map[string]any{"expression": "total + 10", "frameId": 1})
+	if result := decodeBody[struct{ Result string }](t, evaluate).Result; result != "10" {
+		t.Errorf("evaluate = %q, want \"10\"", result)
+	}
+	if m := c.request("evaluate", map[string]any{"expression": "nope(", "frameId": 0}); m.Success {
+		t.Error("evaluating a syntax error succeeded")
+	}
+
+	c.request("stepOut", map[string]any{"threadId": threadID})
+	c.event("stopped")
+	stack = decodeBody[struct{ StackFrames []dapStackFrame }](t, c.request("stackTrace", map[string]any{"threadId": threadID}))
+	if len(stack.StackFrames) != 1 || stack.StackFrames[0].Line != 8 {
+		t.Errorf("stack after stepping out = %+v", stack.StackFrames)
+	}
+
+	c.request("setBreakpoints", map[string]any{"source": map[string]any{"path": path}, "breakpoints": []any{}})
+	c.request("continue", map[string]any{"threadId": threadID})
+	exited, output := c.event("exited")
+	if code := decodeBody[struct{ ExitCode int }](t, exited).ExitCode; code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+	if output != "3\n" {
+		t.Errorf("output = %q, want \"3\\n\"", output)
+	}
+	c.event("terminated")
+
+	c.request("disconnect", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("ServeDAP: %v", err)
+	}
+}
+
+func TestDAPTerminate(t *testing.T) {
+	path := writeProgram(t)
+	c := newDAPClient(t)
+	c.request("initialize", nil)
+	c.request("launch", map[string]any{"program": path, "stopOnEntry": true})
+	c.request("configurationDone", nil)
+	stopped, _ := c.event("stopped")
+	if reason := decodeBody[struct{ Reason string }](t, stopped).Reason; reason != ReasonEntry {
+		t.Errorf("stopped for %q, want %q", reason, ReasonEntry)
+	}
+	c.request("disconnect", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("ServeDAP: %v", err)
+	}
+}
+
+func TestDAPBreakpointsWhileRunning(t *testing.T) {
+	path := writeSource(t, "var i = 0;\nwhile (true) {\n  i = i + 1;\n}\n")
+	c := newDAPClient(t)
+	c.request("initialize", nil)
+	c.request("launch", map[string]any{"program": path})
+	c.request("configurationDone", nil)
+	breakpoints := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []map[string]any{{"line": 3}},
+	})
+	if set := decodeBody[struct{ Breakpoints []dapBreakpoint }](t, breakpoints); len(set.Breakpoints) != 1 || !set.Breakpoints[0].Verified {
+		t.Errorf("setBreakpoints = %s", breakpoints.Body)
+	}
+	stopped, _ := c.event("stopped")
+	if reason := decodeBody[struct{ Reason string }](t, stopped).Reason; reason != ReasonBreakpoint {
+		t.Errorf("stopped for %q, want %q", reason, ReasonBreakpoint)
+	}
+	c.request("disconnect", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("ServeDAP: %v", err)
+	}
+}
+
+func TestDAPStopAfterTerminate(t *testing.T) {
+	// The program can reach a breakpoint after the client has terminated it
+	// but before it has noticed, and mustn't wait to be resumed.
+	a := &adapter{resume: make(chan Action), inspect: make(chan func())}
+	d := New(a.stoppedAt, false)
+	d.Terminate()
+	action := make(chan Action)
+	go func() { action <- a.stoppedAt(d, ReasonBreakpoint) }()
+	select {
+	case got := <-action:
+		if got != Terminate {
+			t.Errorf("stoppedAt returned %v, want Terminate", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stoppedAt blocked after the program was terminated")
+	}
+}
+
+func TestDAPErrors(t *testing.T) {
+	c := newDAPClient(t)
+	tests := []struct {
+		command   string
+		arguments any
+		message   string
+	}{
+		{"configurationDone", nil, "Configuration done before launch"},
+		{"launch", map[string]any{}, "Launch needs a program"},
+		{"bogus", nil, "Unknown command bogus"},
+	}
+	for _, test := range tests {
+		m := c.request(test.command, test.arguments)
+		if m.Success || m.Message != test.message {
+			t.Errorf("%s: success %v, message %q; want failure %q", test.command, m.Success, m.Message, test.message)
+		}
+	}
+	c.request("disconnect", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("ServeDAP: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+// Package debug implements a source-level debugger for Lox programs run by
+// the tree-walking interpreter, with a terminal front end and a Debug
+// Adapter Protocol server.
+package debug
+
+import (
+	"errors"
+	"golox/lox"
+	"golox/lox/stmt"
+	"golox/lox/tok"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Action says how the program should continue after it stops.
+type Action int
+
+const (
+	Continue Action = iota
+	StepIn
+	StepOver
+	StepOut
+	Terminate
+)
+
+// Stop reasons passed to the front end.
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+)
+
+// errTerminated unwinds the program when the front end ends the session.
+var errTerminated = errors.New("Program terminated by the debugger")
+
+// StopFunc is called when the program stops, and blocks until the front
+// end decides how to continue. The program's state can be inspected with
+// the debugger's methods until it returns.
+type StopFunc func(d *Debugger, reason string) Action
+
+// Debugger runs a program, stopping it at breakpoints and after steps.
+type Debugger struct {
+	in          *lox.Interpreter
+	stop        StopFunc
+	breakpoints map[string]map[int]bool
+	stopOnEntry bool
+	action      Action
+	// terminated is set by Terminate, and pending by SetBreakpoints, which
+	// may be called from another goroutine.
+	terminated atomic.Bool
+	mu         sync.Mutex
+	pending    map[string][]int
+	// at is the statement about to run, and depth is the call depth.
+	at    *tok.Token
+	depth int
+	// stopAt and stopDepth are where the program last stopped, and last
+	// is the previous statement.
+	stopAt    *tok.Token
+	stopDepth int
+	last      *tok.Token
+}
+
+// New returns a debugger that calls stop whenever the program stops. The
+// options configure the interpreter; the backend is always the tree
+// walker.
+func New(stop StopFunc, stopOnEntry bool, options ...lox.Option) *Debugger {
+	d := &Debugger{
+		stop:        stop,
+		breakpoints: make(map[string]map[int]bool),
+		stopOnEntry: stopOnEntry,
+	}
+	options = append(options,
+		lox.WithBackend(lox.BackendTreeWalker),
+		lox.WithStatementHook(d.hook))
+	d.in = lox.NewInterpreter(options...)
+	return d
+}
+
+// Run runs the script at path and returns its exit status. If the program
+// is terminated, the status is that of a runtime error.
+func (d *Debugger) Run(path string) (int, error) {
+	if err := d.in.Run(path); err != nil {
+		return 0, err
+	}
+	if d.terminated.Load() {
+		return 70, nil
+	}
+	return d.in.ExitCode(), nil
+}
+
+// SetBreakpoint sets or clears a breakpoint on a line of a file.
+func (d *Debugger) SetBreakpoint(file string, line int, set bool) {
+	file = canonicalPath(file)
+	if set {
+		if d.breakpoints[file] == nil {
+			d.breakpoints[file] = make(map[int]bool)
+		}
+		d.breakpoints[file][line] = true
+	} else {
+		delete(d.breakpoints[file], line)
+	}
+}
+
+// ClearBreakpoints removes all the breakpoints in a file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	delete(d.breakpoints, canonicalPath(file))
+}
+
+// SetBreakpoints replaces the breakpoints in a file. It is safe to call
+// while the program is running, and takes effect before the next
+// statement.
+func (d *Debugger) SetBreakpoints(file string, lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pending == nil {
+		d.pending = make(map[string][]int)
+	}
+	d.pending[canonicalPath(file)] = lines
+}
+
+func (d *Debugger) applyPendingBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for file, lines := range d.pending {
+		d.ClearBreakpoints(file)
+		for _, line := range lines {
+			d.SetBreakpoint(file, line, true)
+		}
+	}
+	d.pending = nil
+}
+
+// Breakpoints returns the lines with breakpoints in a file.
+func (d *Debugger) Breakpoints(file string) []int {
+	var lines []int
+	for line := range d.breakpoints[canonicalPath(file)] {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Position returns the token at the start of the statement the program
+// stopped before.
+func (d *Debugger) Position() *tok.Token {
+	return d.at
+}
+
+// Stack lists the calls in progress from the innermost out.
+func (d *Debugger) Stack() []lox.StackFrame {
+	return d.in.Stack(d.at)
+}
+
+// Locals returns the variables in each scope of a frame, innermost first.
+// Frame 0 is the innermost call.
+func (d *Debugger) Locals(frame int) [][]lox.Variable {
+	return d.in.Locals(frame)
+}
+
+// Globals returns the globals of the module being run.
+func (d *Debugger) Globals() []lox.Variable {
+	return d.in.GlobalVariables()
+}
+
+// Evaluate evaluates an expression in a frame and formats the result.
+func (d *Debugger) Evaluate(frame int, source string) (string, error) {
+	value, err := d.in.Evaluate(frame, source)
+	if err != nil {
+		return "", err
+	}
+	return lox.Repr(value), nil
+}
+
+func (d *Debugger) hook(s stmt.Stmt, at *tok.Token) error {
+	if d.terminated.Load() {
+		return errTerminated
+	}
+	d.applyPendingBreakpoints()
+	switch s.(type) {
+	case *stmt.Block, *stmt.Export:
+		// Stop at the statements inside instead.
+		return nil
+	}
+	if at == nil {
+		return nil
+	}
+
+	d.at = at
+	d.depth = d.in.CallDepth()
+	file := canonicalPath(at.File)
+	newLine := d.last == nil || reached(s, at, d.last)
+	d.last = at
+
+	reason := ""
+	if d.stopOnEntry {
+		d.stopOnEntry = false
+		reason = ReasonEntry
+	} else if d.stepDone(s) {
+		reason = ReasonStep
+	} else if newLine && d.breakpoints[file][at.Line] {
+		reason = ReasonBreakpoint
+	}
+	if reason == "" {
+		return nil
+	}
+
+	d.stopAt, d.stopDepth = at, d.depth
+	d.action = d.stop(d, reason)
+	if d.action == Terminate {
+		d.Terminate()
+		return errTerminated
+	}
+	return nil
+}
+
+// Terminate makes the program stop with an error before its next
+// statement. It is safe to call while the program is running.
+func (d *Debugger) Terminate() {
+	d.terminated.Store(true)
+}
+
+// stepDone reports whether the step the front end asked for is complete.
+// A step into or over ends on a different line from where it started, or
+// when a loop comes back round to where it started.
+func (d *Debugger) stepDone(s stmt.Stmt) bool {
+	if d.stopAt == nil {
+		return false
+	}
+	newLine := reached(s, d.at, d.stopAt)
+	switch d.action {
+	case StepIn:
+		return newLine || d.depth != d.stopDepth
+	case StepOver:
+		return d.depth < d.stopDepth || d.depth == d.stopDepth && newLine
+	case StepOut:
+		return d.depth < d.stopDepth
+	}
+	return false
+}
+
+// reached reports whether the statement s, starting at at, is a new line
+// since prev: it is on another line, or the program has jumped back to the
+// same or an earlier statement on the line, as a one-line loop does on each
+// iteration. A for loop's while statement comes after its initializer, so
+// reaching it is never a jump back.
+func reached(s stmt.Stmt, at *tok.Token, prev *tok.Token) bool {
+	if at.Line != prev.Line || at.File != prev.File {
+		return true
+	}
+	_, isWhile := s.(*stmt.While)
+	return at.Offset <= prev.Offset && !isWhile
+}
+
+func canonicalPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
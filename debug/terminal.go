@@ -0,0 +1,223 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"golox/lox"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const terminalHelp = `Commands:
+  break [file:]line   set a breakpoint (b)
+  clear [file:]line   clear a breakpoint
+  continue            run to the next breakpoint (c)
+  step                step into calls (s)
+  next                step over calls (n)
+  out                 step out of the current function (o)
+  locals              show the variables in the current frame (l)
+  print expr          evaluate an expression in the current frame (p)
+  where               show the call stack (bt)
+  frame n             select a frame from the call stack
+  list                show the source around the current line
+  quit                stop the program (q)
+An empty line repeats the previous command.`
+
+// terminal is a debugger front end that reads commands from a terminal.
+type terminal struct {
+	r       *bufio.Reader
+	w       io.Writer
+	sources map[string][]string
+	frame   int
+	last    string
+}
+
+// RunTerminal debugs the script at path, reading commands from r and
+// writing to w. The program stops before its first statement. The program
+// reads its own input from r too.
+func RunTerminal(path string, r io.Reader, w io.Writer) (int, error) {
+	t := &terminal{
+		r:       bufio.NewReader(r),
+		w:       w,
+		sources: make(map[string][]string),
+	}
+	d := New(t.stopped, true, lox.WithStdin(t.r), lox.WithStdout(w))
+	status, err := d.Run(path)
+	if err == nil {
+		fmt.Fprintf(w, "Program exited with status %d\n", status)
+	}
+	return status, err
+}
+
+func (t *terminal) stopped(d *Debugger, reason string) Action {
+	t.frame = 0
+	at := d.Position()
+	if reason == ReasonBreakpoint {
+		fmt.Fprintf(t.w, "Breakpoint at %s:%d\n", at.File, at.Line)
+	}
+	t.showLine(at.File, at.Line)
+
+	for {
+		fmt.Fprint(t.w, "(debug) ")
+		line, err := t.r.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(t.w)
+			return Terminate
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			line = t.last
+		}
+		t.last = line
+
+		command, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		switch command {
+		case "":
+		case "continue", "c":
+			return Continue
+		case "step", "s":
+			return StepIn
+		case "next", "n":
+			return StepOver
+		case "out", "o":
+			return StepOut
+		case "quit", "q":
+			return Terminate
+		case "break", "b", "clear":
+			file, line, ok := t.location(arg, at.File)
+			if !ok {
+				fmt.Fprintf(t.w, "Usage: %s [file:]line\n", command)
+				continue
+			}
+			d.SetBreakpoint(file, line, command != "clear")
+			if command == "clear" {
+				fmt.Fprintf(t.w, "Cleared breakpoint at %s:%d\n", file, line)
+			} else {
+				fmt.Fprintf(t.w, "Set breakpoint at %s:%d\n", file, line)
+			}
+		case "locals", "l":
+			t.showLocals(d)
+		case "print", "p":
+			if arg == "" {
+				fmt.Fprintln(t.w, "Usage: print expr")
+				continue
+			}
+			value, err := d.Evaluate(t.frame, arg)
+			if err != nil {
+				fmt.Fprintf(t.w, "Error: %s\n", err)
+			} else {
+				fmt.Fprintln(t.w, value)
+			}
+		case "where", "bt":
+			for i, frame := range d.Stack() {
+				marker := " "
+				if i == t.frame {
+					marker = "*"
+				}
+				fmt.Fprintf(t.w, "%s %d %s\n", marker, i, describeFrame(frame))
+			}
+		case "frame":
+			stack := d.Stack()
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(stack) {
+				fmt.Fprintf(t.w, "Usage: frame n, where n is from 0 to %d\n", len(stack)-1)
+				continue
+			}
+			t.frame = n
+			fmt.Fprintf(t.w, "%d %s\n", n, describeFrame(stack[n]))
+			t.showLine(stack[n].Span.File, stack[n].Span.Line)
+		case "list":
+			span := d.Stack()[t.frame].Span
+			t.listSource(span.File, span.Line)
+		case "help", "h", "?":
+			fmt.Fprintln(t.w, terminalHelp)
+		default:
+			fmt.Fprintf(t.w, "Unknown command %q. Type help for a list.\n", command)
+		}
+	}
+}
+
+// location parses a breakpoint location, which is a line in file unless
+// it names another one.
+func (t *terminal) location(arg string, file string) (string, int, bool) {
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file, arg = arg[:i], arg[i+1:]
+	}
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		return "", 0, false
+	}
+	return file, line, true
+}
+
+func (t *terminal) showLocals(d *Debugger) {
+	scopes := d.Locals(t.frame)
+	if len(scopes) == 0 {
+		// Top-level code has no locals.
+		for _, v := range d.Globals() {
+			fmt.Fprintf(t.w, "%s = %s\n", v.Name, lox.Repr(v.Value))
+		}
+		return
+	}
+	// Scopes are separated by a line of dashes, innermost first.
+	shown := false
+	for _, scope := range scopes {
+		if len(scope) == 0 {
+			continue
+		}
+		if shown {
+			fmt.Fprintln(t.w, "--")
+		}
+		shown = true
+		for _, v := range scope {
+			fmt.Fprintf(t.w, "%s = %s\n", v.Name, lox.Repr(v.Value))
+		}
+	}
+}
+
+func (t *terminal) showLine(file string, line int) {
+	if text, ok := t.sourceLine(file, line); ok {
+		fmt.Fprintf(t.w, "%s:%d: %s\n", file, line, text)
+	}
+}
+
+// listSource shows the lines around line, marking it with an arrow.
+func (t *terminal) listSource(file string, line int) {
+	for n := line - 5; n <= line+5; n++ {
+		text, ok := t.sourceLine(file, n)
+		if !ok {
+			continue
+		}
+		marker := "  "
+		if n == line {
+			marker = "=>"
+		}
+		fmt.Fprintf(t.w, "%s %4d  %s\n", marker, n, text)
+	}
+}
+
+func (t *terminal) sourceLine(file string, line int) (string, bool) {
+	lines, ok := t.sources[file]
+	if !ok {
+		bytes, err := os.ReadFile(file)
+		if err == nil {
+			lines = strings.Split(string(bytes), "\n")
+		}
+		t.sources[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
+
+func describeFrame(frame lox.StackFrame) string {
+	name := frame.Function
+	if name == "" {
+		name = "<script>"
+	}
+	return fmt.Sprintf("%s at %s:%d", name, frame.Span.File, frame.Span.Line)
+}
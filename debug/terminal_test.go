@@ -0,0 +1,178 @@
+package debug
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testProgram = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+
+var total = 0;
+for (var i = 0; i < 3; i = i + 1) {
+  total = add(total, i);
+}
+print total;
+`
+
+// writeProgram writes testProgram to a temporary file and returns its path.
+func writeProgram(t *testing.T) string {
+	t.Helper()
+	return writeSource(t, testProgram)
+}
+
+func writeSource(t *testing.T, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.lox")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runTerminal debugs testProgram with commands, returning the output with
+// the program's path replaced by "test.lox".
+func runTerminal(t *testing.T, commands string) (string, int) {
+	t.Helper()
+	return runTerminalSource(t, testProgram, commands)
+}
+
+func runTerminalSource(t *testing.T, source string, commands string) (string, int) {
+	t.Helper()
+	path := writeSource(t, source)
+	var out bytes.Buffer
+	status, err := RunTerminal(path, strings.NewReader(commands), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.ReplaceAll(out.String(), path, "test.lox"), status
+}
+
+func TestTerminalBreakpoints(t *testing.T) {
+	got, status := runTerminal(t, "b 2\nc\nl\np a + b * 100\nwhere\nframe 1\nl\nclear 2\nc\n")
+	want := `test.lox:1: fun add(a, b) {
+(debug) Set breakpoint at test.lox:2
+(debug) Breakpoint at test.lox:2
+test.lox:2:   var sum = a + b;
+(debug) a = 0
+b = 0
+(debug) 0
+(debug) * 0 add at test.lox:2
+  1 <script> at test.lox:8
+(debug) 1 <script> at test.lox:8
+test.lox:8:   total = add(total, i);
+(debug) i = 0
+(debug) Cleared breakpoint at test.lox:2
+(debug) 3
+Program exited with status 0
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if status != 0 {
+		t.Errorf("status = %d, want 0", status)
+	}
+}
+
+func TestTerminalStepping(t *testing.T) {
+	// Step into add and out again, then quit. An empty line repeats the
+	// previous command.
+	got, status := runTerminal(t, "n\n\ns\ns\nn\no\nwhere\nq\n")
+	want := `test.lox:1: fun add(a, b) {
+(debug) test.lox:6: var total = 0;
+(debug) test.lox:7: for (var i = 0; i < 3; i = i + 1) {
+(debug) test.lox:8:   total = add(total, i);
+(debug) test.lox:2:   var sum = a + b;
+(debug) test.lox:3:   return sum;
+(debug) test.lox:8:   total = add(total, i);
+(debug) * 0 <script> at test.lox:8
+(debug) Program exited with status 70
+`
+	if status != 70 {
+		t.Errorf("status = %d, want 70", status)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+const loopProgram = `var i = 0;
+while (i < 3) { print i; i = i + 1; }
+for (var j = 0; j < 2; j = j + 1) print "j";
+print "done";
+`
+
+func TestTerminalBreakpointInOneLineLoop(t *testing.T) {
+	got, _ := runTerminalSource(t, loopProgram, "b 2\nc\nc\nc\nc\n")
+	// The first stop is before the loop starts, and the others are before
+	// each of the following iterations.
+	want := `test.lox:1: var i = 0;
+(debug) Set breakpoint at test.lox:2
+(debug) Breakpoint at test.lox:2
+test.lox:2: while (i < 3) { print i; i = i + 1; }
+(debug) 0
+Breakpoint at test.lox:2
+test.lox:2: while (i < 3) { print i; i = i + 1; }
+(debug) 1
+Breakpoint at test.lox:2
+test.lox:2: while (i < 3) { print i; i = i + 1; }
+(debug) 2
+j
+j
+done
+Program exited with status 0
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTerminalStepOverOneLineLoop(t *testing.T) {
+	// Stepping over from inside the loop stops before the next iteration.
+	got, _ := runTerminalSource(t, loopProgram, "b 2\nc\nc\nclear 2\nn\nn\nn\nn\n")
+	want := `test.lox:1: var i = 0;
+(debug) Set breakpoint at test.lox:2
+(debug) Breakpoint at test.lox:2
+test.lox:2: while (i < 3) { print i; i = i + 1; }
+(debug) 0
+Breakpoint at test.lox:2
+test.lox:2: while (i < 3) { print i; i = i + 1; }
+(debug) Cleared breakpoint at test.lox:2
+(debug) 1
+test.lox:2: while (i < 3) { print i; i = i + 1; }
+(debug) 2
+test.lox:3: for (var j = 0; j < 2; j = j + 1) print "j";
+(debug) j
+j
+test.lox:4: print "done";
+(debug) done
+Program exited with status 0
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTerminalErrors(t *testing.T) {
+	got, status := runTerminal(t, "bogus\nb x\np\np nope(\nframe 5\n")
+	for _, want := range []string{
+		`Unknown command "bogus". Type help for a list.`,
+		"Usage: b [file:]line",
+		"Usage: print expr",
+		"Error: Expect expression.",
+		"Usage: frame n, where n is from 0 to 0",
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("output doesn't contain %q:\n%s", want, got)
+		}
+	}
+	// The program is terminated when the input ends.
+	if status != 70 {
+		t.Errorf("status = %d, want 70", status)
+	}
+}
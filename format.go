@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"golox/lox"
+	"io"
+	"os"
+)
+
+// runFmt implements "golox fmt". With no files it formats standard input.
+// It exits with status 1 if a file can't be formatted or, with --check,
+// isn't formatted already.
+func runFmt(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result back to each file instead of to standard output")
+	check := flags.Bool("check", false, "list the files that aren't formatted instead of formatting them")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: golox fmt [-w] [--check] [file ...]\n")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintf(os.Stderr, "Error: -w needs a file to write to\n")
+			os.Exit(64)
+		}
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		if !formatSource("<stdin>", string(source), false, *check) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	ok := true
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			ok = false
+			continue
+		}
+		if !formatSource(path, string(source), *write, *check) {
+			ok = false
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// formatSource formats one file, reporting whether it succeeded.
+func formatSource(path string, source string, write bool, check bool) bool {
+	formatted, diagnostics := lox.Format(path, source)
+	if len(diagnostics) > 0 {
+		for _, d := range diagnostics {
+			fmt.Fprintln(os.Stderr, d)
+			if snippet := d.Snippet(source); snippet != "" {
+				fmt.Fprintln(os.Stderr, snippet)
+			}
+		}
+		return false
+	}
+
+	switch {
+	case check:
+		if formatted != source {
+			fmt.Println(path)
+			return false
+		}
+	case write:
+		if formatted != source {
+			if err := os.WriteFile(path, []byte(formatted), 0666); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				return false
+			}
+		}
+	default:
+		fmt.Print(formatted)
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, msg := range []any{map[string]any{"id": 1}, "é"} {
+		if err := WriteMessage(&buf, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	for _, want := range []string{`{"id":1}`, `"é"`} {
+		body, err := ReadMessage(r)
+		if err != nil || string(body) != want {
+			t.Errorf("ReadMessage = %q, %v; want %q", body, err, want)
+		}
+	}
+	if _, err := ReadMessage(r); err != io.EOF {
+		t.Errorf("ReadMessage at the end = %v, want EOF", err)
+	}
+}
+
+func TestReadMessageHeaders(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   string
+	}{
+		{"content-length: 2\r\nContent-Type: x\r\n\r\n{}", "{}", ""},
+		{"Content-Length: 2\n\n{}", "{}", ""},
+		{"Content-Type: x\r\n\r\n{}", "", "message has no Content-Length"},
+		{"Content-Length: two\r\n\r\n{}", "", "bad Content-Length:  two"},
+		{"Content-Length: 5\r\n\r\n{}", "", "unexpected EOF"},
+	}
+	for _, test := range tests {
+		body, err := ReadMessage(bufio.NewReader(strings.NewReader(test.input)))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("ReadMessage(%q) error = %v, want %q", test.input, err, test.err)
+			}
+		} else if err != nil || string(body) != test.want {
+			t.Errorf("ReadMessage(%q) = %q, %v; want %q", test.input, body, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"golox/lox"
+	"os"
+)
+
+// runLint implements "golox lint". It exits with status 1 if there are any
+// warnings or errors.
+func runLint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: golox lint file ...\n")
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(64)
+	}
+
+	ok := true
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			ok = false
+			continue
+		}
+		for _, d := range lox.Lint(path, string(source)) {
+			fmt.Printf("%s (%s)\n", d, d.Code)
+			if snippet := d.Snippet(string(source)); snippet != "" {
+				fmt.Println(snippet)
+			}
+			ok = false
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
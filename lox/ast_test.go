@@ -0,0 +1,88 @@
+package lox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func dumpFile(t *testing.T, source string) (string, string, int) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.lox")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	in := NewInterpreter(WithStdout(&stdout), WithStderr(&stderr))
+	if err := in.DumpAST(path); err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String(), stderr.String(), in.ExitCode()
+}
+
+func TestDumpAST(t *testing.T) {
+	source := `var a = 1 + 2 * -b;
+fun f(x) { return x.y(1)[0]; }
+class A < B { m() { super.m(); this.z = [1, {"k": nil}]; } }
+for (var i = 0; i < 2; i = i + 1) { if (!a and true or false) print "s"; else break; }
+try { throw "x"; } catch (e) { continue; } finally { a = nil; }
+import "m.lox" as m;
+export var q = (1);
+while (a) {}
+`
+	want := `(var a = (+ 1 (* 2 (- b))))
+(fun f(x)
+  (return ([] (call (. x y) 1) 0)))
+(class A < B
+  (method m()
+    (; (call (super m)))
+    (; (=. this z (list 1 (map "k" nil))))))
+(block
+  (var i = 0)
+  (while (< i 2) (increment (= i (+ i 1)))
+    (block
+      (if-else (or (and (! a) true) false)
+        (print "s")
+        (break)))))
+(try
+  (block
+    (throw "x"))
+  (catch e
+    (continue))
+  (finally
+    (; (= a nil))))
+(import "m.lox" as m)
+(export
+  (var q = (group 1)))
+(while a
+  (block))
+`
+	stdout, stderr, status := dumpFile(t, source)
+	if stderr != "" || status != 0 {
+		t.Errorf("unexpected errors (status %d):\n%s", status, stderr)
+	}
+	if stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+}
+
+func TestDumpASTSyntaxError(t *testing.T) {
+	stdout, stderr, status := dumpFile(t, "print 1;\nprint ;")
+	if stdout != "" {
+		t.Errorf("printed %q for a script with errors", stdout)
+	}
+	if stderr == "" {
+		t.Error("no diagnostics reported")
+	}
+	if status != 65 {
+		t.Errorf("ExitCode() = %d, want 65", status)
+	}
+}
+
+func TestDumpASTMissingFile(t *testing.T) {
+	in := NewInterpreter()
+	if err := in.DumpAST(filepath.Join(t.TempDir(), "missing.lox")); err == nil {
+		t.Error("DumpAST of a missing file succeeded")
+	}
+}
@@ -1,22 +1,42 @@
 package lox
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
-func init() {
-	globalEnv.Define("clock", &ClockFn{})
+func defineBuiltins(in *Interpreter) {
+	in.DefineNative("clock", 0, clock)
+	in.DefineNative("Error", 1, newError)
+	in.DefineNative("char", 1, char)
+	in.DefineNative("str", 1, str)
+	in.DefineNative("num", 1, num)
+	in.builtins.Define("math", newMathModule())
+	defineIO(in)
 }
 
-type ClockFn struct {
+func clock(args []any) (any, error) {
+	return float64(time.Now().UnixMilli() * 1000), nil
 }
 
-func (c ClockFn) Arity() int {
-	return 0
+func newError(args []any) (any, error) {
+	message, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("Error message must be a string but got %s", typeName(args[0]))
+	}
+	return NewErrorObject(message), nil
 }
 
-func (c ClockFn) Call(arguments []any) (any, error) {
-	return float64(time.Now().UnixMilli() * 1000), nil
+func str(args []any) (any, error) {
+	return stringify(args[0]), nil
 }
 
-func (c ClockFn) String() string {
-	return "<native fn>"
+func num(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case float64:
+		return v, nil
+	case string:
+		return parseNumber(v)
+	}
+	return nil, fmt.Errorf("num expects a string but got %s", typeName(args[0]))
 }
@@ -1,12 +1,14 @@
 package lox
 
 import (
+	"fmt"
 	"golox/lox/stmt"
+	"golox/lox/tok"
 )
 
 type Callable interface {
 	Arity() int
-	Call(arguments []any) (any, error)
+	Call(in *Interpreter, arguments []any) (any, error)
 }
 
 type Return struct {
@@ -19,16 +21,87 @@ func (r *Return) Error() string {
 	return ""
 }
 
+// Break and Continue unwind the stack to the innermost loop, like Return.
+type Break struct{}
+
+func (b *Break) Error() string {
+	return ""
+}
+
+type Continue struct{}
+
+func (c *Continue) Error() string {
+	return ""
+}
+
+// maxFrames limits the depth of Lox calls on both backends, so that
+// runaway recursion is a runtime error rather than a crash.
+const maxFrames = 1 << 14
+
+// maxDepth limits how deeply the tree walker nests statements and
+// expressions, which recurse on the Go stack. It is high enough that
+// ordinary recursion reaches maxFrames first, so that both backends agree.
+const maxDepth = 1 << 18
+
+// callFrame records an active call: the name of the callee, the token of
+// the call site, and the caller's environment.
+type callFrame struct {
+	name string
+	call *tok.Token
+	env  *Environment
+}
+
+// StackFrame is one entry in the traceback of a runtime error. Function is
+// empty for top-level code.
+type StackFrame struct {
+	Function string
+	Span     Span
+}
+
+func (in *Interpreter) stackTrace(err *Error) []StackFrame {
+	return in.stack(err.Span())
+}
+
+// stack lists the active calls from the innermost out, where span is the
+// position in the innermost one.
+func (in *Interpreter) stack(span Span) []StackFrame {
+	var trace []StackFrame
+	for i := len(in.frames) - 1; i >= 0; i-- {
+		trace = append(trace, StackFrame{Function: in.frames[i].name, Span: span})
+		if in.frames[i].call == nil {
+			// Called from Go, so there is no Lox call site.
+			return trace
+		}
+		span = tokenSpan(in.frames[i].call)
+	}
+	return append(trace, StackFrame{Span: span})
+}
+
+// checkArity reports an error if a call passes the wrong number of
+// arguments.
+func checkArity(arity int, argCount int, paren *tok.Token) error {
+	if arity != Variadic && argCount != arity {
+		return &Error{
+			Token: paren,
+			Message: fmt.Sprintf("Expected %d arguments but got %d",
+				arity, argCount),
+		}
+	}
+	return nil
+}
+
 type Function struct {
 	declaration   *stmt.Function
 	closure       *Environment
+	globals       *Globals
 	isInitializer bool
 }
 
-func NewFunction(declaration *stmt.Function, closure *Environment, isInitializer bool) *Function {
+func NewFunction(declaration *stmt.Function, closure *Environment, globals *Globals, isInitializer bool) *Function {
 	return &Function{
 		declaration:   declaration,
 		closure:       closure,
+		globals:       globals,
 		isInitializer: isInitializer,
 	}
 }
@@ -37,17 +110,24 @@ func (f *Function) Arity() int {
 	return len(f.declaration.Params)
 }
 
-func (f *Function) Call(arguments []any) (any, error) {
+func (f *Function) Call(in *Interpreter, arguments []any) (any, error) {
+	if in.profiler != nil {
+		in.profiler.enter(f.declaration)
+		defer in.profiler.exit()
+	}
 	e := NewEnvironment(f.closure)
-	for i, param := range f.declaration.Params {
-		e.Define(param.Lexeme, arguments[i])
+	for i, arg := range arguments {
+		in.defineLocal(e, f.declaration.Params[i].Lexeme, arg)
 	}
-	err := execBlock(f.declaration.Body, e)
+	globals := in.globals
+	in.globals = f.globals
+	err := in.execBlock(f.declaration.Body, e)
+	in.globals = globals
 	if err != nil {
 		ret, ok := err.(*Return)
 		if ok {
 			if f.isInitializer {
-				return f.closure.GetAt(0, "this"), nil
+				return f.closure.GetAt(0, 0), nil
 			}
 			return ret.Value, nil
 		}
@@ -55,16 +135,21 @@ func (f *Function) Call(arguments []any) (any, error) {
 	}
 
 	if f.isInitializer {
-		return f.closure.GetAt(0, "this"), nil
+		return f.closure.GetAt(0, 0), nil
 	}
 
 	return nil, nil
 }
 
-func (f *Function) Bind(instance *Instance) *Function {
+var thisName = []string{"this"}
+
+func (f *Function) Bind(instance *Instance) Callable {
+	// Methods are bound whenever they are accessed, so rather than check
+	// whether the debugger needs the name, they all share one slice.
 	e := NewEnvironment(f.closure)
-	e.Define("this", instance)
-	return NewFunction(f.declaration, e, f.isInitializer)
+	e.names = thisName
+	e.Define(instance)
+	return NewFunction(f.declaration, e, f.globals, f.isInitializer)
 }
 
 func (f *Function) String() string {
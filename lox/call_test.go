@@ -0,0 +1,95 @@
+package lox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTraceback(t *testing.T) {
+	source := `fun inner() {
+  return nil + 1;
+}
+fun outer() {
+  inner();
+}
+outer();
+`
+	_, stderr := runBoth(t, source)
+	want := `operands should be numbers or strings
+[line 2] in inner()
+[line 5] in outer()
+[line 7] in script
+2 |   return nil + 1;
+  |              ^
+`
+	if stderr != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stderr, want)
+	}
+}
+
+func TestTracebackThroughMethods(t *testing.T) {
+	source := `class A {
+  init(x) { this.x = x; }
+  get() { return this.missing; }
+}
+A(1).get();
+`
+	_, stderr := runBoth(t, source)
+	want := "Undefined property 'missing'\n[line 3] in get()\n[line 5] in script\n"
+	if !strings.HasPrefix(stderr, want) {
+		t.Errorf("got:\n%s\nwant it to start with:\n%s", stderr, want)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	source := "fun f(n) {\n  return f(n + 1);\n}\nf(0);\n"
+	_, stderr := runBoth(t, source)
+	lines := strings.Split(stderr, "\n")
+	if lines[0] != "Stack overflow" {
+		t.Fatalf("got %q, want a stack overflow", lines[0])
+	}
+	// The middle of the traceback is elided.
+	if len(lines) > maxTraceFrames+5 {
+		t.Errorf("traceback has %d lines, want at most %d", len(lines), maxTraceFrames+5)
+	}
+	elided := lines[maxTraceFrames/2+1]
+	if !strings.HasPrefix(elided, "... ") || !strings.HasSuffix(elided, " more frames ...") {
+		t.Errorf("got %q, want a count of the elided frames", elided)
+	}
+}
+
+func TestStackOverflowCanBeCaught(t *testing.T) {
+	source := `fun f() { f(); }
+try {
+  f();
+} catch (e) {
+  print e.message;
+}
+`
+	expectOutput(t, source, "Stack overflow\n")
+}
+
+func TestStackOverflowInDeeplyNestedCode(t *testing.T) {
+	// Each call nests deeply, so the Go stack would run out long before
+	// maxFrames calls.
+	nested := strings.Repeat("{ if (true) ", 500) + "f();" + strings.Repeat(" }", 500)
+	expectOutput(t, "fun f() "+nested+"\ntry { f(); } catch (e) { print e.message; }", "Stack overflow\n")
+}
+
+func TestRethrowKeepsTrace(t *testing.T) {
+	source := `fun f(n) {
+  try {
+    if (n == 0) throw Error("deep");
+    f(n - 1);
+  } catch (e) {
+    throw e;
+  }
+}
+f(5000);
+`
+	_, stderr := runBoth(t, source)
+	lines := strings.Split(stderr, "\n")
+	if len(lines) < 3 || lines[0] != "deep" || lines[1] != "[line 3] in f()" || lines[2] != "[line 4] in f()" {
+		t.Errorf("got:\n%s\nwant the trace from where the error was first thrown", stderr)
+	}
+}
@@ -0,0 +1,160 @@
+package lox
+
+import (
+	"golox/lox/tok"
+)
+
+type opCode byte
+
+const (
+	opConstant opCode = iota
+	opNil
+	opTrue
+	opFalse
+	opPop
+	opGetLocal
+	opSetLocal
+	opGetGlobal
+	opDefineGlobal
+	opSetGlobal
+	opGetUpvalue
+	opSetUpvalue
+	opGetProperty
+	opSetProperty
+	opGetSuper
+	opList
+	opMap
+	opGetIndex
+	opSetIndex
+	opEqual
+	opNotEqual
+	opGreater
+	opGreaterEqual
+	opLess
+	opLessEqual
+	opAdd
+	opSubtract
+	opMultiply
+	opDivide
+	opModulo
+	opNot
+	opNegate
+	opPrint
+	opJump
+	opJumpIfFalse
+	opLoop
+	opTry
+	opEndTry
+	opThrow
+	opCatch
+	opCall
+	opInvoke
+	opClosure
+	opCloseUpvalue
+	opReturn
+	opImport
+	opClass
+	opInherit
+	opMethod
+)
+
+var opNames = [...]string{
+	opConstant:     "OP_CONSTANT",
+	opNil:          "OP_NIL",
+	opTrue:         "OP_TRUE",
+	opFalse:        "OP_FALSE",
+	opPop:          "OP_POP",
+	opGetLocal:     "OP_GET_LOCAL",
+	opSetLocal:     "OP_SET_LOCAL",
+	opGetGlobal:    "OP_GET_GLOBAL",
+	opDefineGlobal: "OP_DEFINE_GLOBAL",
+	opSetGlobal:    "OP_SET_GLOBAL",
+	opGetUpvalue:   "OP_GET_UPVALUE",
+	opSetUpvalue:   "OP_SET_UPVALUE",
+	opGetProperty:  "OP_GET_PROPERTY",
+	opSetProperty:  "OP_SET_PROPERTY",
+	opGetSuper:     "OP_GET_SUPER",
+	opList:         "OP_LIST",
+	opMap:          "OP_MAP",
+	opGetIndex:     "OP_GET_INDEX",
+	opSetIndex:     "OP_SET_INDEX",
+	opEqual:        "OP_EQUAL",
+	opNotEqual:     "OP_NOT_EQUAL",
+	opGreater:      "OP_GREATER",
+	opGreaterEqual: "OP_GREATER_EQUAL",
+	opLess:         "OP_LESS",
+	opLessEqual:    "OP_LESS_EQUAL",
+	opAdd:          "OP_ADD",
+	opSubtract:     "OP_SUBTRACT",
+	opMultiply:     "OP_MULTIPLY",
+	opDivide:       "OP_DIVIDE",
+	opModulo:       "OP_MODULO",
+	opNot:          "OP_NOT",
+	opNegate:       "OP_NEGATE",
+	opPrint:        "OP_PRINT",
+	opJump:         "OP_JUMP",
+	opJumpIfFalse:  "OP_JUMP_IF_FALSE",
+	opLoop:         "OP_LOOP",
+	opTry:          "OP_TRY",
+	opEndTry:       "OP_END_TRY",
+	opThrow:        "OP_THROW",
+	opCatch:        "OP_CATCH",
+	opCall:         "OP_CALL",
+	opInvoke:       "OP_INVOKE",
+	opClosure:      "OP_CLOSURE",
+	opCloseUpvalue: "OP_CLOSE_UPVALUE",
+	opReturn:       "OP_RETURN",
+	opImport:       "OP_IMPORT",
+	opClass:        "OP_CLASS",
+	opInherit:      "OP_INHERIT",
+	opMethod:       "OP_METHOD",
+}
+
+func (op opCode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return "???"
+}
+
+// chunk is a sequence of bytecode instructions with its constant pool. Each
+// byte of code records the token it was compiled from, so that runtime
+// errors can be reported at the right place in the source.
+type chunk struct {
+	code          []byte
+	tokens        []*tok.Token
+	constants     []any
+	constantIndex map[any]int
+}
+
+func (c *chunk) write(b byte, t *tok.Token) {
+	c.code = append(c.code, b)
+	c.tokens = append(c.tokens, t)
+}
+
+func (c *chunk) addConstant(value any) int {
+	if index, ok := c.constantIndex[value]; ok {
+		return index
+	}
+	if c.constantIndex == nil {
+		c.constantIndex = make(map[any]int)
+	}
+	c.constants = append(c.constants, value)
+	c.constantIndex[value] = len(c.constants) - 1
+	return len(c.constants) - 1
+}
+
+// token returns the token for the instruction at offset, falling back to
+// the nearest preceding instruction that has one.
+func (c *chunk) token(offset int) *tok.Token {
+	for i := offset; i >= 0; i-- {
+		if c.tokens[i] != nil {
+			return c.tokens[i]
+		}
+	}
+	return nil
+}
+
+func (c *chunk) readShort(offset int) int {
+	return int(c.code[offset])<<8 | int(c.code[offset+1])
+}
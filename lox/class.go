@@ -1,12 +1,18 @@
 package lox
 
+// Method is a function that can be bound to an instance of a class.
+type Method interface {
+	Callable
+	Bind(instance *Instance) Callable
+}
+
 type Class struct {
 	name       string
 	superclass *Class
-	methods    map[string]*Function
+	methods    map[string]Method
 }
 
-func NewClass(name string, superclass *Class, methods map[string]*Function) *Class {
+func NewClass(name string, superclass *Class, methods map[string]Method) *Class {
 	return &Class{
 		name:       name,
 		superclass: superclass,
@@ -22,12 +28,12 @@ func (c *Class) Arity() int {
 	return 0
 }
 
-func (c *Class) Call(args []any) (any, error) {
+func (c *Class) Call(in *Interpreter, args []any) (any, error) {
 	instance := NewInstance(c)
 
 	initializer := c.FindMethod("init")
 	if initializer != nil {
-		_, err := initializer.Bind(instance).Call(args)
+		_, err := initializer.Bind(instance).Call(in, args)
 		if err != nil {
 			return nil, err
 		}
@@ -40,7 +46,7 @@ func (c *Class) String() string {
 	return c.name
 }
 
-func (c *Class) FindMethod(name string) *Function {
+func (c *Class) FindMethod(name string) Method {
 	method := c.methods[name]
 	if method != nil {
 		return method
@@ -0,0 +1,703 @@
+package lox
+
+import (
+	"golox/lox/expr"
+	"golox/lox/stmt"
+	"golox/lox/tok"
+	"math"
+)
+
+const maxLocals = math.MaxUint8 + 1
+
+type local struct {
+	name       string
+	depth      int
+	isCaptured bool
+}
+
+// loop tracks the jumps for break and continue statements in a loop body
+// that are patched once the end of the loop is known.
+type loop struct {
+	start        int
+	scopeDepth   int
+	hasIncrement bool
+	breaks       []int
+	continues    []int
+}
+
+// tryBlock is a try statement whose body or catch clause is being
+// compiled. Its finally block must run before any jump out of it, and
+// loops is the number of enclosing loops.
+type tryBlock struct {
+	finally *stmt.Block
+	loops   int
+}
+
+type upvalueRef struct {
+	index   byte
+	isLocal bool
+}
+
+// compiler turns the statements of one function body into bytecode. Nested
+// functions get their own compiler, linked through enclosing so that
+// variables can be captured as upvalues.
+type compiler struct {
+	enclosing    *compiler
+	function     *proto
+	functionType FunctionType
+	locals       []local
+	upvalues     []upvalueRef
+	loops        []*loop
+	tries        []tryBlock
+	scopeDepth   int
+	token        *tok.Token
+	err          *Error
+}
+
+func newCompiler(enclosing *compiler, ft FunctionType, name string) *compiler {
+	c := &compiler{
+		enclosing:    enclosing,
+		function:     &proto{name: name},
+		functionType: ft,
+	}
+
+	// Slot zero holds the receiver for methods, and the function being
+	// called otherwise.
+	if ft == FunctionTypeMethod || ft == FunctionTypeInitializer {
+		c.locals = append(c.locals, local{name: "this"})
+	} else {
+		c.locals = append(c.locals, local{name: ""})
+	}
+
+	return c
+}
+
+// compile compiles a resolved program into a function that runs it.
+func compile(statements []stmt.Stmt) (*proto, *Error) {
+	c := newCompiler(nil, FunctionTypeNone, "")
+	c.statements(statements)
+	c.emitReturn()
+	return c.function, c.err
+}
+
+func (c *compiler) error(message string) {
+	if c.err == nil {
+		c.err = &Error{Token: c.token, Code: CodeCompilerLimit, Message: message}
+	}
+}
+
+func (c *compiler) chunk() *chunk {
+	return &c.function.chunk
+}
+
+func (c *compiler) emit(bs ...byte) {
+	for _, b := range bs {
+		c.chunk().write(b, c.token)
+	}
+}
+
+func (c *compiler) emitOp(op opCode) {
+	c.emit(byte(op))
+}
+
+func (c *compiler) emitShort(op opCode, operand int) {
+	c.emit(byte(op), byte(operand>>8), byte(operand))
+}
+
+func (c *compiler) emitConstant(value any) {
+	c.emitShort(opConstant, c.makeConstant(value))
+}
+
+func (c *compiler) makeConstant(value any) int {
+	index := c.chunk().addConstant(value)
+	if index > math.MaxUint16 {
+		c.error("Too many constants in one chunk")
+		return 0
+	}
+	return index
+}
+
+func (c *compiler) emitJump(op opCode) int {
+	c.emit(byte(op), 0xff, 0xff)
+	return len(c.chunk().code) - 2
+}
+
+func (c *compiler) patchJump(offset int) {
+	jump := len(c.chunk().code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.error("Too much code to jump over")
+	}
+	c.chunk().code[offset] = byte(jump >> 8)
+	c.chunk().code[offset+1] = byte(jump)
+}
+
+func (c *compiler) emitLoop(loopStart int) {
+	offset := len(c.chunk().code) - loopStart + 3
+	if offset > math.MaxUint16 {
+		c.error("Loop body too large")
+	}
+	c.emitShort(opLoop, offset)
+}
+
+func (c *compiler) emitReturn() {
+	c.emitImplicitReturnValue()
+	c.emitOp(opReturn)
+}
+
+func (c *compiler) emitImplicitReturnValue() {
+	if c.functionType == FunctionTypeInitializer {
+		c.emit(byte(opGetLocal), 0)
+	} else {
+		c.emitOp(opNil)
+	}
+}
+
+func (c *compiler) beginScope() {
+	c.scopeDepth++
+}
+
+func (c *compiler) endScope() {
+	c.scopeDepth--
+	for len(c.locals) > 0 && c.locals[len(c.locals)-1].depth > c.scopeDepth {
+		if c.locals[len(c.locals)-1].isCaptured {
+			c.emitOp(opCloseUpvalue)
+		} else {
+			c.emitOp(opPop)
+		}
+		c.locals = c.locals[:len(c.locals)-1]
+	}
+}
+
+func (c *compiler) addLocal(name string) {
+	if len(c.locals) == maxLocals {
+		c.error("Too many local variables in function")
+		return
+	}
+	c.locals = append(c.locals, local{name: name, depth: c.scopeDepth})
+}
+
+// defineVariable binds the value on top of the stack to name, either as a
+// new local in the current scope or as a global.
+func (c *compiler) defineVariable(name *tok.Token) {
+	if c.scopeDepth > 0 {
+		c.addLocal(name.Lexeme)
+		return
+	}
+	c.emitShort(opDefineGlobal, c.makeConstant(name.Lexeme))
+}
+
+func (c *compiler) resolveLocal(name string) int {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *compiler) resolveUpvalue(name string) int {
+	if c.enclosing == nil {
+		return -1
+	}
+
+	if local := c.enclosing.resolveLocal(name); local != -1 {
+		c.enclosing.locals[local].isCaptured = true
+		return c.addUpvalue(byte(local), true)
+	}
+
+	if upvalue := c.enclosing.resolveUpvalue(name); upvalue != -1 {
+		return c.addUpvalue(byte(upvalue), false)
+	}
+
+	return -1
+}
+
+func (c *compiler) addUpvalue(index byte, isLocal bool) int {
+	for i, u := range c.upvalues {
+		if u.index == index && u.isLocal == isLocal {
+			return i
+		}
+	}
+
+	if len(c.upvalues) == maxLocals {
+		c.error("Too many closure variables in function")
+		return 0
+	}
+
+	c.upvalues = append(c.upvalues, upvalueRef{index: index, isLocal: isLocal})
+	c.function.upvalueCount = len(c.upvalues)
+	return len(c.upvalues) - 1
+}
+
+func (c *compiler) namedVariable(name *tok.Token, assign bool) {
+	c.token = name
+	c.variable(name.Lexeme, assign)
+}
+
+func (c *compiler) variable(name string, assign bool) {
+	getOp, setOp := opGetGlobal, opSetGlobal
+	var arg int
+	if arg = c.resolveLocal(name); arg != -1 {
+		getOp, setOp = opGetLocal, opSetLocal
+	} else if arg = c.resolveUpvalue(name); arg != -1 {
+		getOp, setOp = opGetUpvalue, opSetUpvalue
+	} else {
+		if assign {
+			c.emitShort(setOp, c.makeConstant(name))
+		} else {
+			c.emitShort(getOp, c.makeConstant(name))
+		}
+		return
+	}
+
+	if assign {
+		c.emit(byte(setOp), byte(arg))
+	} else {
+		c.emit(byte(getOp), byte(arg))
+	}
+}
+
+func (c *compiler) statements(statements []stmt.Stmt) {
+	for _, s := range statements {
+		c.statement(s)
+	}
+}
+
+func (c *compiler) statement(st stmt.Stmt) {
+	switch s := st.(type) {
+	case *stmt.Print:
+		c.expression(s.Expression)
+		c.emitOp(opPrint)
+	case *stmt.Expression:
+		c.expression(s.Expression)
+		c.emitOp(opPop)
+	case *stmt.If:
+		c.ifStmt(s)
+	case *stmt.While:
+		c.whileStmt(s)
+	case *stmt.Break:
+		c.breakStmt(s)
+	case *stmt.Continue:
+		c.continueStmt(s)
+	case *stmt.Var:
+		if s.Initializer != nil {
+			c.expression(s.Initializer)
+		} else {
+			c.emitOp(opNil)
+		}
+		c.token = s.Name
+		c.defineVariable(s.Name)
+	case *stmt.Block:
+		c.beginScope()
+		c.statements(s.Statements)
+		c.endScope()
+	case *stmt.Function:
+		c.token = s.Name
+		// Declare a local function before compiling its body, so that it
+		// can refer to itself recursively.
+		if c.scopeDepth > 0 {
+			c.addLocal(s.Name.Lexeme)
+			c.functionDecl(s, FunctionTypeFunction)
+		} else {
+			c.functionDecl(s, FunctionTypeFunction)
+			c.defineVariable(s.Name)
+		}
+	case *stmt.Return:
+		c.returnStmt(s)
+	case *stmt.Import:
+		c.token = s.Path
+		c.emitShort(opImport, c.makeConstant(s))
+		c.token = s.Name
+		c.defineVariable(s.Name)
+	case *stmt.Export:
+		c.statement(s.Declaration)
+	case *stmt.Throw:
+		c.expression(s.Value)
+		c.token = s.Keyword
+		c.emitOp(opThrow)
+	case *stmt.Try:
+		c.tryStmt(s)
+	case *stmt.Class:
+		c.classStmt(s)
+	}
+}
+
+func (c *compiler) ifStmt(s *stmt.If) {
+	c.expression(s.Condition)
+	thenJump := c.emitJump(opJumpIfFalse)
+	c.emitOp(opPop)
+	c.statement(s.ThenBranch)
+	elseJump := c.emitJump(opJump)
+	c.patchJump(thenJump)
+	c.emitOp(opPop)
+	if s.ElseBranch != nil {
+		c.statement(s.ElseBranch)
+	}
+	c.patchJump(elseJump)
+}
+
+func (c *compiler) whileStmt(s *stmt.While) {
+	loopStart := len(c.chunk().code)
+	c.expression(s.Condition)
+	exitJump := c.emitJump(opJumpIfFalse)
+	c.emitOp(opPop)
+	l := &loop{
+		start:        loopStart,
+		scopeDepth:   c.scopeDepth,
+		hasIncrement: s.Increment != nil,
+	}
+	c.loops = append(c.loops, l)
+	c.statement(s.Body)
+	c.loops = c.loops[:len(c.loops)-1]
+	if s.Increment != nil {
+		for _, jump := range l.continues {
+			c.patchJump(jump)
+		}
+		c.expression(s.Increment)
+		c.emitOp(opPop)
+	}
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	c.emitOp(opPop)
+	for _, jump := range l.breaks {
+		c.patchJump(jump)
+	}
+}
+
+func (c *compiler) returnStmt(s *stmt.Return) {
+	c.token = s.Keyword
+	if s.Value == nil {
+		c.emitImplicitReturnValue()
+	} else {
+		c.expression(s.Value)
+	}
+	if len(c.tries) > 0 {
+		// Keep the return value in a local while the finally blocks run, so
+		// that locals they declare get the right slots.
+		c.beginScope()
+		c.addLocal("")
+		c.exitTries(0)
+		c.token = s.Keyword
+		c.emitOp(opReturn)
+		c.scopeDepth--
+		c.locals = c.locals[:len(c.locals)-1]
+		return
+	}
+	c.emitOp(opReturn)
+}
+
+func (c *compiler) tryStmt(s *stmt.Try) {
+	c.token = s.Keyword
+	handler := c.emitJump(opTry)
+	c.tries = append(c.tries, tryBlock{finally: s.Finally, loops: len(c.loops)})
+	c.statement(s.Body)
+	c.tries = c.tries[:len(c.tries)-1]
+	c.token = s.Keyword
+	c.emitOp(opEndTry)
+	c.finally(s.Finally)
+	exits := []int{c.emitJump(opJump)}
+
+	// The handler starts with the *Error on top of the stack, which opCatch
+	// converts to the value bound by the catch clause.
+	c.patchJump(handler)
+	if s.Catch != nil {
+		c.emitOp(opCatch)
+		if s.Finally != nil {
+			handler = c.emitJump(opTry)
+			c.tries = append(c.tries, tryBlock{finally: s.Finally, loops: len(c.loops)})
+		}
+		c.beginScope()
+		c.addLocal(s.CatchName.Lexeme)
+		c.statements(s.Catch.Statements)
+		c.endScope()
+		if s.Finally == nil {
+			c.patchJumps(exits)
+			return
+		}
+		c.tries = c.tries[:len(c.tries)-1]
+		c.token = s.Keyword
+		c.emitOp(opEndTry)
+		c.finally(s.Finally)
+		exits = append(exits, c.emitJump(opJump))
+		c.patchJump(handler)
+	}
+
+	// Run the finally block and rethrow.
+	c.beginScope()
+	c.addLocal("")
+	c.finally(s.Finally)
+	c.token = s.Keyword
+	c.emitOp(opThrow)
+	c.scopeDepth--
+	c.locals = c.locals[:len(c.locals)-1]
+	c.patchJumps(exits)
+}
+
+func (c *compiler) finally(block *stmt.Block) {
+	if block != nil {
+		c.statement(block)
+	}
+}
+
+// exitTries emits the code to leave the try statements in c.tries[depth:],
+// innermost first, removing their handlers and running their finally
+// blocks.
+func (c *compiler) exitTries(depth int) {
+	tries := c.tries
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.tries = tries[:i]
+		c.emitOp(opEndTry)
+		c.finally(tries[i].finally)
+	}
+	c.tries = tries
+}
+
+func (c *compiler) patchJumps(jumps []int) {
+	for _, jump := range jumps {
+		c.patchJump(jump)
+	}
+}
+
+// loopTries returns the index in c.tries of the first try statement inside
+// the innermost loop.
+func (c *compiler) loopTries() int {
+	i := len(c.tries)
+	for i > 0 && c.tries[i-1].loops >= len(c.loops) {
+		i--
+	}
+	return i
+}
+
+func (c *compiler) breakStmt(s *stmt.Break) {
+	l := c.loops[len(c.loops)-1]
+	c.token = s.Keyword
+	c.exitTries(c.loopTries())
+	c.token = s.Keyword
+	c.discardLocals(l.scopeDepth)
+	l.breaks = append(l.breaks, c.emitJump(opJump))
+}
+
+func (c *compiler) continueStmt(s *stmt.Continue) {
+	l := c.loops[len(c.loops)-1]
+	c.token = s.Keyword
+	c.exitTries(c.loopTries())
+	c.token = s.Keyword
+	c.discardLocals(l.scopeDepth)
+	if l.hasIncrement {
+		l.continues = append(l.continues, c.emitJump(opJump))
+	} else {
+		c.emitLoop(l.start)
+	}
+}
+
+// discardLocals pops the locals declared deeper than depth without ending
+// their scopes, for jumps out of a loop body. A local may be captured by a
+// closure further down the body, so their upvalues are always closed.
+func (c *compiler) discardLocals(depth int) {
+	for i := len(c.locals) - 1; i >= 0 && c.locals[i].depth > depth; i-- {
+		c.emitOp(opCloseUpvalue)
+	}
+}
+
+func (c *compiler) functionDecl(s *stmt.Function, ft FunctionType) {
+	fc := newCompiler(c, ft, s.Name.Lexeme)
+	fc.token = s.Name
+	fc.function.arity = len(s.Params)
+	fc.beginScope()
+	for _, param := range s.Params {
+		fc.addLocal(param.Lexeme)
+	}
+	fc.statements(s.Body)
+	fc.token = s.Name
+	fc.emitReturn()
+	if fc.err != nil && c.err == nil {
+		c.err = fc.err
+	}
+
+	c.token = s.Name
+	c.emitShort(opClosure, c.makeConstant(fc.function))
+	for _, u := range fc.upvalues {
+		if u.isLocal {
+			c.emit(1, u.index)
+		} else {
+			c.emit(0, u.index)
+		}
+	}
+}
+
+func (c *compiler) classStmt(s *stmt.Class) {
+	c.token = s.Name
+	name := c.makeConstant(s.Name.Lexeme)
+	c.emitShort(opClass, name)
+	c.defineVariable(s.Name)
+
+	if s.Superclass != nil {
+		c.namedVariable(s.Superclass.Name, false)
+		c.beginScope()
+		c.addLocal("super")
+		c.namedVariable(s.Name, false)
+		c.token = s.Superclass.Name
+		c.emitOp(opInherit)
+	}
+
+	c.namedVariable(s.Name, false)
+	for _, m := range s.Methods {
+		if m.Name.Lexeme == "init" {
+			c.functionDecl(m, FunctionTypeInitializer)
+		} else {
+			c.functionDecl(m, FunctionTypeMethod)
+		}
+		c.emitShort(opMethod, c.makeConstant(m.Name.Lexeme))
+	}
+	c.emitOp(opPop)
+
+	if s.Superclass != nil {
+		c.endScope()
+	}
+}
+
+func (c *compiler) expression(ex expr.Expr) {
+	switch e := ex.(type) {
+	case *expr.Literal:
+		switch e.Value {
+		case nil:
+			c.emitOp(opNil)
+		case true:
+			c.emitOp(opTrue)
+		case false:
+			c.emitOp(opFalse)
+		default:
+			c.emitConstant(e.Value)
+		}
+	case *expr.Grouping:
+		c.expression(e.Expression)
+	case *expr.Unary:
+		c.expression(e.Right)
+		c.token = e.Operator
+		if e.Operator.Type == tok.Minus {
+			c.emitOp(opNegate)
+		} else {
+			c.emitOp(opNot)
+		}
+	case *expr.Binary:
+		c.expression(e.Left)
+		c.expression(e.Right)
+		c.token = e.Operator
+		c.emitOp(binaryOps[e.Operator.Type])
+	case *expr.Logical:
+		c.logical(e)
+	case *expr.Variable:
+		c.namedVariable(e.Name, false)
+	case *expr.Assign:
+		c.expression(e.Value)
+		c.namedVariable(e.Name, true)
+	case *expr.Call:
+		c.call(e)
+	case *expr.Get:
+		c.expression(e.Object)
+		c.token = e.Name
+		c.emitShort(opGetProperty, c.makeConstant(e.Name.Lexeme))
+	case *expr.Set:
+		c.expression(e.Object)
+		c.expression(e.Value)
+		c.token = e.Name
+		c.emitShort(opSetProperty, c.makeConstant(e.Name.Lexeme))
+	case *expr.List:
+		for _, element := range e.Elements {
+			c.expression(element)
+		}
+		c.token = e.Bracket
+		if len(e.Elements) > math.MaxUint16 {
+			c.error("Too many elements in list literal")
+		}
+		c.emitShort(opList, len(e.Elements))
+	case *expr.Map:
+		for i := range e.Keys {
+			c.expression(e.Keys[i])
+			c.expression(e.Values[i])
+		}
+		c.token = e.Brace
+		if len(e.Keys) > math.MaxUint16 {
+			c.error("Too many entries in map literal")
+		}
+		c.emitShort(opMap, len(e.Keys))
+	case *expr.Index:
+		c.expression(e.Object)
+		c.expression(e.Index)
+		c.token = e.Bracket
+		c.emitOp(opGetIndex)
+	case *expr.SetIndex:
+		c.expression(e.Object)
+		c.expression(e.Index)
+		c.expression(e.Value)
+		c.token = e.Bracket
+		c.emitOp(opSetIndex)
+	case *expr.This:
+		c.namedVariable(e.Keyword, false)
+	case *expr.Super:
+		c.token = e.Keyword
+		c.variable("this", false)
+		c.variable("super", false)
+		c.emitShort(opGetSuper, c.makeConstant(e.Method.Lexeme))
+	}
+}
+
+var binaryOps = map[tok.Type]opCode{
+	tok.EqualEqual:   opEqual,
+	tok.BangEqual:    opNotEqual,
+	tok.Greater:      opGreater,
+	tok.GreaterEqual: opGreaterEqual,
+	tok.Less:         opLess,
+	tok.LessEqual:    opLessEqual,
+	tok.Plus:         opAdd,
+	tok.Minus:        opSubtract,
+	tok.Star:         opMultiply,
+	tok.Slash:        opDivide,
+	tok.Percent:      opModulo,
+}
+
+func (c *compiler) logical(e *expr.Logical) {
+	c.expression(e.Left)
+	var jump int
+	if e.Operator.Type == tok.Or {
+		elseJump := c.emitJump(opJumpIfFalse)
+		jump = c.emitJump(opJump)
+		c.patchJump(elseJump)
+	} else {
+		jump = c.emitJump(opJumpIfFalse)
+	}
+	c.emitOp(opPop)
+	c.expression(e.Right)
+	c.patchJump(jump)
+}
+
+func (c *compiler) call(e *expr.Call) {
+	if len(e.Arguments) > math.MaxUint8 {
+		c.token = e.Paren
+		c.error("Can't have more than 255 arguments")
+		return
+	}
+
+	// Calling a method directly avoids creating a bound method object.
+	if get, ok := e.Callee.(*expr.Get); ok {
+		c.expression(get.Object)
+		for _, a := range e.Arguments {
+			c.expression(a)
+		}
+		// The name and the argument count carry different tokens, so that
+		// a missing method is reported at its name and a bad call at the
+		// parenthesis, as in the tree walker.
+		c.token = get.Name
+		c.emitShort(opInvoke, c.makeConstant(get.Name.Lexeme))
+		c.token = e.Paren
+		c.emit(byte(len(e.Arguments)))
+		return
+	}
+
+	c.expression(e.Callee)
+	for _, a := range e.Arguments {
+		c.expression(a)
+	}
+	c.token = e.Paren
+	c.emit(byte(opCall), byte(len(e.Arguments)))
+}
@@ -0,0 +1,253 @@
+package lox
+
+import (
+	"strings"
+	"testing"
+)
+
+// conformanceTests are run on both backends, which must print the same
+// output and report the same errors.
+var conformanceTests = []struct {
+	name   string
+	source string
+	want   string
+	// err is the first line of the runtime or compile error, if any.
+	err string
+}{
+	{
+		name:   "arithmetic",
+		source: "print 1 + 2 * 3; print (1 + 2) * 3; print 10 / 4; print -(-3); print 7 % 4; print -7 % 3;",
+		want:   "7\n9\n2.5\n3\n3\n-1\n",
+	},
+	{
+		name:   "comparison",
+		source: "print 1 < 2; print 2 <= 2; print 3 > 4; print 4 >= 5; print 1 == 1; print 1 != 1;",
+		want:   "true\ntrue\nfalse\nfalse\ntrue\nfalse\n",
+	},
+	{
+		name:   "equality across types",
+		source: `print nil == nil; print nil == false; print 1 == "1"; print "a" == "a"; print true != false;`,
+		want:   "true\nfalse\nfalse\ntrue\ntrue\n",
+	},
+	{
+		name:   "truthiness",
+		source: `print !nil; print !false; print !0; print !""; print nil or "default"; print false and "no"; print 1 and 2;`,
+		want:   "true\ntrue\nfalse\nfalse\ndefault\nfalse\n2\n",
+	},
+	{
+		name:   "strings",
+		source: `var s = "con" + "cat"; print s; print s == "concat";`,
+		want:   "concat\ntrue\n",
+	},
+	{
+		name:   "number formatting",
+		source: "print 1 / 3; print 100; print 0.5; print -0.25; print 100000000000000000000 * 10000; print 0.000000001;",
+		want:   "0.3333333333333333\n100\n0.5\n-0.25\n1e+24\n1e-09\n",
+	},
+	{
+		name: "scopes",
+		source: `var a = "global";
+{
+  var a = "outer";
+  {
+    var a = "inner";
+    print a;
+  }
+  print a;
+}
+print a;`,
+		want: "inner\nouter\nglobal\n",
+	},
+	{
+		name:   "assignment is an expression",
+		source: "var a; var b; a = b = 3; print a; print b;",
+		want:   "3\n3\n",
+	},
+	{
+		name: "control flow",
+		source: `var i = 0;
+while (i < 3) { if (i == 1) print "one"; else print i; i = i + 1; }
+for (var j = 3; j > 0; j = j - 1) print j;
+var s = 0;
+for (var k = 0; k < 100; k = k + 1) s = s + k;
+print s;`,
+		want: "0\none\n2\n3\n2\n1\n4950\n",
+	},
+	{
+		name: "functions",
+		source: `fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); }
+print fib(15);
+fun noReturn() {}
+print noReturn();
+print fib;
+print clock;`,
+		want: "610\nnil\n<fn fib>\n<native fn>\n",
+	},
+	{
+		name: "closures",
+		source: `fun makeCounter() {
+  var i = 0;
+  fun count() { i = i + 1; return i; }
+  return count;
+}
+var c1 = makeCounter();
+var c2 = makeCounter();
+c1();
+print c1();
+print c2();
+var get; var set;
+{
+  var shared = "before";
+  fun g() { return shared; }
+  fun s() { shared = "after"; }
+  get = g; set = s;
+}
+set();
+print get();`,
+		want: "2\n1\nafter\n",
+	},
+	{
+		name: "closures capture each loop iteration's variables",
+		source: `var fs = [];
+for (var i = 0; i < 3; i = i + 1) {
+  var j = i;
+  fun f() { return j; }
+  fs.push(f);
+}
+print fs[0]() + fs[1]() + fs[2]();`,
+		want: "3\n",
+	},
+	{
+		name: "static resolution",
+		source: `var a = "global";
+{
+  fun show() { print a; }
+  show();
+  var a = "block";
+  show();
+}`,
+		want: "global\nglobal\n",
+	},
+	{
+		name: "classes",
+		source: `class Point {
+  init(x, y) { this.x = x; this.y = y; }
+  sum() { return this.x + this.y; }
+}
+var p = Point(1, 2);
+print p.sum();
+p.x = 10;
+print p.sum();
+var m = p.sum;
+print m();
+print p;
+print Point;
+print p.init(3, 4) == p;`,
+		want: "3\n12\n12\nPoint instance\nPoint\ntrue\n",
+	},
+	{
+		name: "inheritance",
+		source: `class A {
+  name() { return "A"; }
+  greet() { return "I am " + this.name(); }
+}
+class B < A {
+  name() { return "B"; }
+  greet() { return super.greet() + "!"; }
+}
+class C < B {}
+print A().greet();
+print C().greet();`,
+		want: "I am A\nI am B!\n",
+	},
+	{
+		name: "this in closures",
+		source: `class Box {
+  init(v) { this.v = v; }
+  getter() { fun get() { return this.v; } return get; }
+}
+print Box("boxed").getter()();`,
+		want: "boxed\n",
+	},
+	{
+		name:   "wrong number of arguments",
+		source: "fun f(a, b) { return a; }\nf(1);",
+		err:    "Expected 2 arguments but got 1",
+	},
+	{
+		name:   "unary operand must be a number",
+		source: `print -"x";`,
+		err:    "operand must be a number",
+	},
+	{
+		name:   "binary operands must be numbers",
+		source: `print 1 < "2";`,
+		err:    "operands must be numbers",
+	},
+	{
+		name:   "adding mismatched types",
+		source: `print 1 + "2";`,
+		err:    "operands should be numbers or strings",
+	},
+	{
+		name:   "undefined variable",
+		source: "print missing;",
+		err:    "Undefined variable 'missing'",
+	},
+	{
+		name:   "assigning an undefined variable",
+		source: "missing = 1;",
+		err:    "Undefined variable 'missing'",
+	},
+	{
+		name:   "calling a non-callable",
+		source: `"text"();`,
+		err:    "Can only call functions and classes",
+	},
+	{
+		name:   "fields on non-instances",
+		source: "var n = 1; n.x = 2;",
+		err:    "Only instances have fields",
+	},
+	{
+		name:   "undefined property",
+		source: "class A {} print A().missing;",
+		err:    "Undefined property 'missing'",
+	},
+	{
+		name:   "superclass must be a class",
+		source: "var NotAClass = 1; class A < NotAClass {}",
+		err:    "Superclass must be a class",
+	},
+	{
+		name:   "modulo by zero",
+		source: "print 1 % 0;",
+		err:    "Modulo by zero",
+	},
+	{
+		name:   "modulo by a fraction that truncates to zero",
+		source: "print 5 % 0.5;",
+		err:    "Modulo by zero",
+	},
+	{
+		name:   "output before a runtime error is kept",
+		source: "print 1; print nil + 1; print 2;",
+		want:   "1\n",
+		err:    "operands should be numbers or strings",
+	},
+}
+
+func TestConformance(t *testing.T) {
+	for _, test := range conformanceTests {
+		t.Run(test.name, func(t *testing.T) {
+			stdout, stderr := runBoth(t, test.source)
+			if stdout != test.want {
+				t.Errorf("output:\n%s\nwant:\n%s", stdout, test.want)
+			}
+			got, _, _ := strings.Cut(stderr, "\n")
+			if got != test.err {
+				t.Errorf("error = %q, want %q", got, test.err)
+			}
+		})
+	}
+}
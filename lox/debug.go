@@ -0,0 +1,170 @@
+package lox
+
+import (
+	"errors"
+	"golox/lox/expr"
+	"golox/lox/stmt"
+	"golox/lox/tok"
+	"sort"
+)
+
+// StatementHook is called by the tree-walking interpreter before it runs
+// each statement, with the token at the start of the statement, which may
+// be nil. If the hook returns an error, the statement fails with it.
+type StatementHook func(s stmt.Stmt, at *tok.Token) error
+
+// Variable is a named value, as shown by a debugger.
+type Variable struct {
+	Name  string
+	Value any
+}
+
+// Repr formats a value for display by tools, quoting strings.
+func Repr(value any) string {
+	return repr(value, map[any]bool{})
+}
+
+// CallDepth returns the number of calls in progress.
+func (in *Interpreter) CallDepth() int {
+	return len(in.frames)
+}
+
+// Stack lists the calls in progress from the innermost out, where at is
+// the token being executed in the innermost call.
+func (in *Interpreter) Stack(at *tok.Token) []StackFrame {
+	return in.stack(tokenSpan(at))
+}
+
+// frameEnv returns the environment of a frame on the stack, counting from
+// 0 for the innermost.
+func (in *Interpreter) frameEnv(frame int) *Environment {
+	if frame <= 0 || frame > len(in.frames) {
+		return in.env
+	}
+	return in.frames[len(in.frames)-frame].env
+}
+
+// Locals returns the local variables visible in a frame on the stack, one
+// slice for each scope from the innermost out.
+func (in *Interpreter) Locals(frame int) [][]Variable {
+	var scopes [][]Variable
+	for env := in.frameEnv(frame); env != nil; env = env.enclosing {
+		scope := make([]Variable, len(env.values))
+		for i, value := range env.values {
+			scope[i] = Variable{Name: env.name(i), Value: value}
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// GlobalVariables returns the globals of the module being run, sorted by
+// name. Builtins are not included.
+func (in *Interpreter) GlobalVariables() []Variable {
+	var variables []Variable
+	for name, value := range in.globals.values {
+		variables = append(variables, Variable{Name: name, Value: value})
+	}
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Name < variables[j].Name
+	})
+	return variables
+}
+
+// Evaluate evaluates an expression as if it appeared in a frame on the
+// stack. The statement hook isn't called while it runs.
+func (in *Interpreter) Evaluate(frame int, source string) (any, error) {
+	scanner := NewScanner(source)
+	parser := NewParser(scanner.ScanTokens())
+	e, err := parser.expression()
+	if err == nil && !parser.isAtEnd() {
+		err = parser.error(parser.peek(), CodeExpectedToken, "Expect end of expression")
+	}
+	diagnostics := append(scanner.Diagnostics(), parser.Diagnostics()...)
+	if len(diagnostics) > 0 {
+		return nil, errors.New(diagnostics[0].Message)
+	} else if err != nil {
+		return nil, err
+	}
+
+	env := in.frameEnv(frame)
+	var bound []expr.Expr
+	in.bindNames(e, env, &bound)
+	previousEnv, hook := in.env, in.statementHook
+	in.env, in.statementHook = env, nil
+	defer func() {
+		in.env, in.statementHook = previousEnv, hook
+		for _, b := range bound {
+			delete(in.locals, b)
+		}
+	}()
+	return in.Eval(e)
+}
+
+// bindNames does the resolver's job for an expression evaluated by the
+// debugger, finding each variable by name in the environment it will be
+// evaluated in. The expressions it binds are added to bound, so that they
+// can be forgotten afterwards.
+func (in *Interpreter) bindNames(ex expr.Expr, env *Environment, bound *[]expr.Expr) {
+	bind := func(e expr.Expr, name string) {
+		depth := 0
+		for scope := env; scope != nil; scope = scope.enclosing {
+			for slot := len(scope.names) - 1; slot >= 0; slot-- {
+				if scope.names[slot] == name {
+					in.resolve(e, depth, slot)
+					*bound = append(*bound, e)
+					return
+				}
+			}
+			depth++
+		}
+	}
+
+	switch e := ex.(type) {
+	case *expr.Variable:
+		bind(e, e.Name.Lexeme)
+	case *expr.Assign:
+		in.bindNames(e.Value, env, bound)
+		bind(e, e.Name.Lexeme)
+	case *expr.This:
+		bind(e, "this")
+	case *expr.Super:
+		bind(e, "super")
+	case *expr.Binary:
+		in.bindNames(e.Left, env, bound)
+		in.bindNames(e.Right, env, bound)
+	case *expr.Logical:
+		in.bindNames(e.Left, env, bound)
+		in.bindNames(e.Right, env, bound)
+	case *expr.Grouping:
+		in.bindNames(e.Expression, env, bound)
+	case *expr.Unary:
+		in.bindNames(e.Right, env, bound)
+	case *expr.Call:
+		in.bindNames(e.Callee, env, bound)
+		for _, arg := range e.Arguments {
+			in.bindNames(arg, env, bound)
+		}
+	case *expr.Get:
+		in.bindNames(e.Object, env, bound)
+	case *expr.Set:
+		in.bindNames(e.Object, env, bound)
+		in.bindNames(e.Value, env, bound)
+	case *expr.List:
+		for _, element := range e.Elements {
+			in.bindNames(element, env, bound)
+		}
+	case *expr.Map:
+		for i := range e.Keys {
+			in.bindNames(e.Keys[i], env, bound)
+			in.bindNames(e.Values[i], env, bound)
+		}
+	case *expr.Index:
+		in.bindNames(e.Object, env, bound)
+		in.bindNames(e.Index, env, bound)
+	case *expr.SetIndex:
+		in.bindNames(e.Object, env, bound)
+		in.bindNames(e.Index, env, bound)
+		in.bindNames(e.Value, env, bound)
+	}
+}
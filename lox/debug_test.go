@@ -0,0 +1,126 @@
+package lox
+
+import (
+	"bytes"
+	"golox/lox/stmt"
+	"golox/lox/tok"
+	"reflect"
+	"testing"
+)
+
+const debugScript = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+class Box {
+  init(v) { this.v = v; }
+  get() {
+    return this.v;
+  }
+}
+print add(1, 2);
+print Box(7).get();
+`
+
+// runWithHook runs debugScript with a statement hook that calls stop
+// before each statement on line.
+func runWithHook(t *testing.T, line int, stop func(in *Interpreter)) {
+	t.Helper()
+	var out, errs bytes.Buffer
+	var in *Interpreter
+	in = NewInterpreter(WithStdout(&out), WithStderr(&errs),
+		WithStatementHook(func(s stmt.Stmt, at *tok.Token) error {
+			if at != nil && at.Line == line {
+				stop(in)
+			}
+			return nil
+		}))
+	in.run("test.lox", debugScript)
+	if errs.Len() > 0 || out.String() != "3\n7\n" {
+		t.Fatalf("script printed %q, %q", out.String(), errs.String())
+	}
+}
+
+func TestLocals(t *testing.T) {
+	var got [][]Variable
+	runWithHook(t, 3, func(in *Interpreter) {
+		got = in.Locals(0)
+	})
+	want := [][]Variable{{{"a", 1.0}, {"b", 2.0}, {"sum", 3.0}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Locals(0) = %v, want %v", got, want)
+	}
+}
+
+func TestLocalsIncludeThis(t *testing.T) {
+	var got [][]Variable
+	runWithHook(t, 8, func(in *Interpreter) {
+		got = in.Locals(0)
+	})
+	if len(got) != 2 || len(got[1]) != 1 || got[1][0].Name != "this" {
+		t.Errorf("Locals(0) = %v, want the method's scope and then this", got)
+	}
+}
+
+func TestLocalNamesOnlyRecordedWhenDebugging(t *testing.T) {
+	var out bytes.Buffer
+	in := NewInterpreter(WithStdout(&out))
+	var got [][]Variable
+	in.DefineNative("probe", 0, func(args []any) (any, error) {
+		got = in.Locals(0)
+		return nil, nil
+	})
+	in.run("test.lox", "fun f(a) { var b = 2; probe(); } f(1);")
+	want := [][]Variable{{{"?", 1.0}, {"?", 2.0}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Locals(0) = %v, want %v", got, want)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		frame  int
+		source string
+		want   any
+	}{
+		{0, "a + b * 100", 201.0},
+		{0, "sum", 3.0},
+		{0, "add(sum, 10)", 13.0},
+		{1, "add", nil},
+	}
+	runWithHook(t, 3, func(in *Interpreter) {
+		for _, test := range tests {
+			before := len(in.locals)
+			got, err := in.Evaluate(test.frame, test.source)
+			if err != nil {
+				t.Errorf("Evaluate(%d, %q): %v", test.frame, test.source, err)
+				continue
+			}
+			if test.want != nil && got != test.want {
+				t.Errorf("Evaluate(%d, %q) = %v, want %v", test.frame, test.source, got, test.want)
+			}
+			if after := len(in.locals); after != before {
+				t.Errorf("Evaluate(%d, %q) left %d bindings behind", test.frame, test.source, after-before)
+			}
+		}
+	})
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"a +", "Expect expression."},
+		{"a b", "Expect end of expression"},
+		{"missing", "Undefined variable 'missing'"},
+	}
+	runWithHook(t, 3, func(in *Interpreter) {
+		for _, test := range tests {
+			_, err := in.Evaluate(0, test.source)
+			if err == nil || err.Error() != test.want {
+				t.Errorf("Evaluate(%q) error = %v, want %q", test.source, err, test.want)
+			}
+		}
+	})
+}
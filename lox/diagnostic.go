@@ -0,0 +1,222 @@
+package lox
+
+import (
+	"fmt"
+	"golox/lox/tok"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "???"
+	}
+}
+
+type Phase int
+
+const (
+	PhaseScan Phase = iota
+	PhaseParse
+	PhaseResolve
+	PhaseCompile
+	PhaseRuntime
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseScan:
+		return "scan"
+	case PhaseParse:
+		return "parse"
+	case PhaseResolve:
+		return "resolve"
+	case PhaseCompile:
+		return "compile"
+	case PhaseRuntime:
+		return "runtime"
+	default:
+		return "???"
+	}
+}
+
+// Code identifies the kind of problem a diagnostic reports, so that tools
+// can match on it without parsing the message text.
+type Code string
+
+const (
+	CodeUnexpectedCharacter    Code = "unexpected-character"
+	CodeUnterminatedString     Code = "unterminated-string"
+	CodeExpectedToken          Code = "expected-token"
+	CodeExpectedExpression     Code = "expected-expression"
+	CodeTooManyParameters      Code = "too-many-parameters"
+	CodeTooManyArguments       Code = "too-many-arguments"
+	CodeInvalidAssignment      Code = "invalid-assignment"
+	CodeTopLevelReturn         Code = "top-level-return"
+	CodeBreakOutsideLoop       Code = "break-outside-loop"
+	CodeContinueOutsideLoop    Code = "continue-outside-loop"
+	CodeNotTopLevel            Code = "not-top-level"
+	CodeInitializerReturn      Code = "initializer-return"
+	CodeSelfInheritance        Code = "self-inheritance"
+	CodeSelfInitializer        Code = "self-initializer"
+	CodeThisOutsideClass       Code = "this-outside-class"
+	CodeSuperOutsideClass      Code = "super-outside-class"
+	CodeSuperWithoutSuperclass Code = "super-without-superclass"
+	CodeRedeclaration          Code = "redeclaration"
+	CodeCompilerLimit          Code = "compiler-limit"
+	CodeFormat                 Code = "format"
+	CodeRuntime                Code = "runtime"
+
+	// Warnings reported by the linter.
+
+	CodeUnusedVariable    Code = "unused-variable"
+	CodeUnusedParameter   Code = "unused-parameter"
+	CodeUnreachableCode   Code = "unreachable-code"
+	CodeShadowedVariable  Code = "shadowed-variable"
+	CodeUndeclaredGlobal  Code = "undeclared-global"
+	CodeConstantCondition Code = "constant-condition"
+)
+
+// Span is a range of source text. Line and Column are 1-based; a zero
+// Column means the column is unknown. Offset and Length are in bytes.
+type Span struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
+	Length int
+}
+
+func tokenSpan(t *tok.Token) Span {
+	return Span{
+		File:   t.File,
+		Line:   t.Line,
+		Column: t.Column,
+		Offset: t.Offset,
+		Length: len(t.Lexeme),
+	}
+}
+
+const maxTraceFrames = 20
+
+type Diagnostic struct {
+	Severity Severity
+	Phase    Phase
+	Code     Code
+	Message  string
+	Span     Span
+	Trace    []StackFrame
+	where    string
+	// mainFile is the file name of the main script. Locations in other
+	// files include the file name.
+	mainFile string
+}
+
+func newTokenDiagnostic(phase Phase, err *Error) *Diagnostic {
+	where := " at '" + err.Token.Lexeme + "'"
+	if err.Token.Type == tok.EOF {
+		where = " at end"
+	}
+	return &Diagnostic{
+		Severity: SeverityError,
+		Phase:    phase,
+		Code:     err.Code,
+		Message:  err.Message,
+		Span:     err.Span(),
+		where:    where,
+	}
+}
+
+func (d *Diagnostic) String() string {
+	if d.Phase == PhaseRuntime {
+		if len(d.Trace) == 0 {
+			return fmt.Sprintf("%s\n[%s]", d.Message, d.location(d.Span))
+		}
+		sb := &strings.Builder{}
+		sb.WriteString(d.Message)
+		for i, frame := range d.Trace {
+			// Elide the middle of very deep tracebacks, such as those from
+			// runaway recursion.
+			if len(d.Trace) > maxTraceFrames && i >= maxTraceFrames/2 &&
+				i < len(d.Trace)-maxTraceFrames/2 {
+				if i == maxTraceFrames/2 {
+					fmt.Fprintf(sb, "\n... %d more frames ...", len(d.Trace)-maxTraceFrames)
+				}
+				continue
+			}
+			if frame.Function == "" {
+				fmt.Fprintf(sb, "\n[%s] in script", d.location(frame.Span))
+			} else {
+				fmt.Fprintf(sb, "\n[%s] in %s()", d.location(frame.Span), frame.Function)
+			}
+		}
+		return sb.String()
+	}
+	kind := "Error"
+	if d.Severity == SeverityWarning {
+		kind = "Warning"
+	}
+	return fmt.Sprintf("[%s] %s%s: %s", d.location(d.Span), kind, d.where, d.Message)
+}
+
+// location describes where span is, naming the file only if it isn't the
+// main script.
+func (d *Diagnostic) location(span Span) string {
+	if span.File != d.mainFile {
+		return fmt.Sprintf("line %d in %s", span.Line, span.File)
+	}
+	return fmt.Sprintf("line %d", span.Line)
+}
+
+// Snippet returns the source line that the diagnostic refers to, with the
+// span underlined by carets. It returns "" if the span has no column.
+func (d *Diagnostic) Snippet(source string) string {
+	if d.Span.Column == 0 {
+		return ""
+	}
+
+	lines := strings.Split(source, "\n")
+	if d.Span.Line < 1 || d.Span.Line > len(lines) {
+		return ""
+	}
+	line := strings.TrimRight(lines[d.Span.Line-1], "\r")
+
+	start := d.Span.Column - 1
+	if start > len(line) {
+		start = len(line)
+	}
+	length := d.Span.Length
+	if start+length > len(line) {
+		length = len(line) - start
+	}
+	if length < 1 {
+		length = 1
+	}
+
+	// Copy tabs from the source line so that the carets line up however
+	// the terminal renders them.
+	underline := &strings.Builder{}
+	for _, c := range line[:start] {
+		if c == '\t' {
+			underline.WriteRune('\t')
+		} else {
+			underline.WriteRune(' ')
+		}
+	}
+	underline.WriteString(strings.Repeat("^", length))
+
+	gutter := fmt.Sprintf("%d", d.Span.Line)
+	return fmt.Sprintf("%s | %s\n%s | %s", gutter, line,
+		strings.Repeat(" ", len(gutter)), underline.String())
+}
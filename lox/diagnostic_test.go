@@ -0,0 +1,109 @@
+package lox
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		source string
+		phase  Phase
+		code   Code
+		span   Span
+		text   string
+	}{
+		{
+			"var x = @;", PhaseScan, CodeUnexpectedCharacter,
+			Span{File: "f.lox", Line: 1, Column: 9, Offset: 8, Length: 1},
+			"[line 1] Error: Unexpected character.",
+		},
+		{
+			"print (1;", PhaseParse, CodeExpectedToken,
+			Span{File: "f.lox", Line: 1, Column: 9, Offset: 8, Length: 1},
+			"[line 1] Error at ';': Expect ')' after expression",
+		},
+		{
+			"{\n  var a = 1;\n  var a = 2;\n}", PhaseResolve, CodeRedeclaration,
+			Span{File: "f.lox", Line: 3, Column: 7, Offset: 21, Length: 1},
+			"[line 3] Error at 'a': Already a variable with this name in this scope",
+		},
+		{
+			"return 1;", PhaseResolve, CodeTopLevelReturn,
+			Span{File: "f.lox", Line: 1, Column: 1, Offset: 0, Length: 6},
+			"[line 1] Error at 'return': Can't return from top-level code",
+		},
+		{
+			"print 1 + nil;", PhaseRuntime, CodeRuntime,
+			Span{File: "f.lox", Line: 1, Column: 9, Offset: 8, Length: 1},
+			"operands should be numbers or strings\n[line 1]",
+		},
+	}
+	for _, test := range tests {
+		for _, b := range backends {
+			var diagnostics []*Diagnostic
+			in := NewInterpreter(WithBackend(b.backend), WithDiagnosticHandler(func(d *Diagnostic) {
+				diagnostics = append(diagnostics, d)
+			}))
+			in.run("f.lox", test.source)
+			if len(diagnostics) == 0 {
+				t.Errorf("%s: no diagnostics for %q", b.name, test.source)
+				continue
+			}
+			d := diagnostics[0]
+			if d.Severity != SeverityError || d.Phase != test.phase || d.Code != test.code {
+				t.Errorf("%s: %q: got %v %v %q, want error %v %q",
+					b.name, test.source, d.Severity, d.Phase, d.Code, test.phase, test.code)
+			}
+			if d.Span != test.span {
+				t.Errorf("%s: %q: span = %+v, want %+v", b.name, test.source, d.Span, test.span)
+			}
+			if d.String() != test.text {
+				t.Errorf("%s: %q: String() = %q, want %q", b.name, test.source, d.String(), test.text)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var stdout bytes.Buffer
+	in := NewInterpreter(WithStdout(&stdout))
+	statements, diagnostics := in.Check("f.lox", `print "side effect";`)
+	if len(statements) != 1 || len(diagnostics) != 0 {
+		t.Errorf("Check returned %d statements and %v", len(statements), diagnostics)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Check ran the program, printing %q", stdout.String())
+	}
+
+	_, diagnostics = in.Check("f.lox", "print 1;\nprint (;\nvar;")
+	if len(diagnostics) != 2 || diagnostics[0].Span.Line != 2 || diagnostics[1].Span.Line != 3 {
+		t.Errorf("Check reported %v, want errors on lines 2 and 3", diagnostics)
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	// Each statement after the error should be parsed, and its own error
+	// reported.
+	for _, second := range []string{"break x;", "continue x;", "throw;", "try;", "import;", "export;"} {
+		in := NewInterpreter()
+		_, diagnostics := in.Check("f.lox", "var x = )\n"+second)
+		if len(diagnostics) != 2 || diagnostics[1].Span.Line != 2 {
+			t.Errorf("%q: Check reported %v, want errors on lines 1 and 2", second, diagnostics)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	d := &Diagnostic{Span: Span{Line: 2, Column: 8, Length: 3}}
+	source := "var a = 1;\n\tprint foo;\n"
+	want := "2 | \tprint foo;\n  | \t      ^^^"
+	if got := d.Snippet(source); got != want {
+		t.Errorf("Snippet() = %q, want %q", got, want)
+	}
+
+	d.Span.Column = 0
+	if got := d.Snippet(source); got != "" {
+		t.Errorf("Snippet() without a column = %q, want nothing", got)
+	}
+}
@@ -1,69 +1,105 @@
 package lox
 
 import (
-	"golox/lox/expr"
 	"golox/lox/tok"
 )
 
-var globalEnv = NewEnvironment(nil)
-var currentEnv = globalEnv
-var depthMap = make(map[expr.Expr]int)
-
+// Environment holds the local variables of one scope. The resolver assigns
+// each local a slot in the order it is declared, and the interpreter
+// defines them in the same order, so variables can be found by index. The
+// names are only needed by the debugger, so they are only recorded while
+// there is a statement hook.
 type Environment struct {
 	enclosing *Environment
-	values    map[string]any
+	names     []string
+	values    []any
 }
 
 func NewEnvironment(enclosing *Environment) *Environment {
 	return &Environment{
 		enclosing: enclosing,
-		values:    make(map[string]any),
 	}
 }
 
-func (e *Environment) Define(name string, value any) {
-	e.values[name] = value
+func (e *Environment) Define(value any) {
+	e.values = append(e.values, value)
 }
 
-func (e *Environment) Get(name *tok.Token) (any, error) {
-	val, ok := e.values[name.Lexeme]
-	if ok {
-		return val, nil
+// name returns the name of the variable in slot, or "?" if it wasn't
+// recorded.
+func (e *Environment) name(slot int) string {
+	if slot < len(e.names) {
+		return e.names[slot]
 	}
+	return "?"
+}
 
-	if e.enclosing != nil {
-		return e.enclosing.Get(name)
-	}
+func (e *Environment) GetAt(distance int, slot int) any {
+	return e.ancestor(distance).values[slot]
+}
 
-	return nil, &Error{Token: name, Message: "Undefined variable '" + name.Lexeme + "'"}
+func (e *Environment) AssignAt(distance int, slot int, value any) {
+	e.ancestor(distance).values[slot] = value
 }
 
-func (e *Environment) Assign(name *tok.Token, value any) error {
-	_, ok := e.values[name.Lexeme]
-	if ok {
-		e.values[name.Lexeme] = value
-		return nil
+func (e *Environment) ancestor(distance int) *Environment {
+	a := e
+	for i := 0; i < distance; i++ {
+		a = a.enclosing
 	}
+	return a
+}
+
+// Globals holds the global variables of a module. Names that aren't
+// defined in the module are looked up in the enclosing globals, which hold
+// the builtins shared by all modules.
+type Globals struct {
+	enclosing *Globals
+	values    map[string]any
+}
 
-	if e.enclosing != nil {
-		return e.enclosing.Assign(name, value)
+func NewGlobals(enclosing *Globals) *Globals {
+	return &Globals{
+		enclosing: enclosing,
+		values:    make(map[string]any),
 	}
+}
 
-	return &Error{Token: name, Message: "Undefined variable '" + name.Lexeme + "'"}
+func (g *Globals) Define(name string, value any) {
+	g.values[name] = value
 }
 
-func (e *Environment) GetAt(distance int, name string) any {
-	return e.ancestor(distance).values[name]
+func (g *Globals) Lookup(name string) (any, bool) {
+	for ; g != nil; g = g.enclosing {
+		if val, ok := g.values[name]; ok {
+			return val, true
+		}
+	}
+	return nil, false
 }
 
-func (e *Environment) AssignAt(distance int, name *tok.Token, value any) {
-	e.ancestor(distance).values[name.Lexeme] = value
+func (g *Globals) Get(name *tok.Token) (any, error) {
+	val, ok := g.Lookup(name.Lexeme)
+	if ok {
+		return val, nil
+	}
+	return nil, &Error{Token: name, Message: "Undefined variable '" + name.Lexeme + "'"}
 }
 
-func (e *Environment) ancestor(distance int) *Environment {
-	a := e
-	for i := 0; i < distance; i++ {
-		a = a.enclosing
+func (g *Globals) Assign(name *tok.Token, value any) error {
+	if g.set(name.Lexeme, value) {
+		return nil
 	}
-	return a
+	return &Error{Token: name, Message: "Undefined variable '" + name.Lexeme + "'"}
+}
+
+// set assigns to an existing global, reporting whether there was one.
+func (g *Globals) set(name string, value any) bool {
+	for ; g != nil; g = g.enclosing {
+		if _, ok := g.values[name]; ok {
+			g.values[name] = value
+			return true
+		}
+	}
+	return false
 }
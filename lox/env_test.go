@@ -0,0 +1,62 @@
+package lox
+
+import "testing"
+
+func TestLocalSlots(t *testing.T) {
+	source := `fun f(a, b) {
+  var c = a + b;
+  {
+    var d = c * 2;
+    var e = d + 1;
+    a = e;
+  }
+  var g = "after block";
+  return a + c;
+}
+print f(1, 2);
+{
+  var x = 1;
+  var y = 2;
+  {
+    var x = 10;
+    print x + y;
+  }
+  print x + y;
+}`
+	expectOutput(t, source, "10\n12\n3\n")
+}
+
+func TestCatchVariableSlot(t *testing.T) {
+	source := `fun f() {
+  var before = "b";
+  try {
+    var inside = 1;
+    throw "boom";
+  } catch (e) {
+    var after = "a";
+    print before + e + after;
+  }
+}
+f();`
+	expectOutput(t, source, "bbooma\n")
+}
+
+func TestClassLocalsAndSuper(t *testing.T) {
+	source := `fun make() {
+  var prefix = "<";
+  class Base { tag() { return "base"; } }
+  class Derived < Base {
+    tag() { return prefix + super.tag() + ">"; }
+  }
+  return Derived();
+}
+print make().tag();`
+	expectOutput(t, source, "<base>\n")
+}
+
+func TestGlobalsBeforeDeclaration(t *testing.T) {
+	source := `fun early() { return late; }
+var late = "declared later";
+print early();`
+	expectOutput(t, source, "declared later\n")
+}
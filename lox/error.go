@@ -7,34 +7,58 @@ import (
 
 type Error struct {
 	Token   *tok.Token
+	Code    Code
 	Message string
+	// Trace is the Lox call stack at the point a runtime error was raised,
+	// innermost frame first. It is nil for errors raised in top-level code.
+	Trace []StackFrame
+	// Value is the value thrown by a throw statement, or nil if the error
+	// was raised by the interpreter.
+	Value any
 }
 
 func (e *Error) Error() string {
 	return e.Message
 }
 
-var HadError = false
-var HadRuntimeError = false
-
-func report(line int, where string, message string) {
-	fmt.Printf("[line %d] Error%s: %s\n", line, where, message)
-	HadError = true
-}
-
-func ReportScanError(line int, message string) {
-	report(line, "", message)
+func (e *Error) Span() Span {
+	return tokenSpan(e.Token)
 }
 
-func ReportParseError(err *Error) {
-	if err.Token.Type == tok.EOF {
-		report(err.Token.Line, " at end", err.Message)
-	} else {
-		report(err.Token.Line, " at '"+err.Token.Lexeme+"'", err.Message)
+func (in *Interpreter) report(d *Diagnostic) {
+	d.mainFile = in.main.path
+	if d.Severity == SeverityError {
+		if d.Phase == PhaseRuntime {
+			in.hadRuntimeError = true
+		} else {
+			in.hadError = true
+		}
+	}
+	if in.diagnosticHandler != nil {
+		in.diagnosticHandler(d)
+		return
+	}
+	fmt.Fprintln(in.stderr, d)
+	if source, ok := in.sources[d.Span.File]; ok {
+		if snippet := d.Snippet(source); snippet != "" {
+			fmt.Fprintln(in.stderr, snippet)
+		}
 	}
 }
 
-func ReportRuntimeError(err *Error) {
-	fmt.Printf("%s\n[line %d]\n", err.Message, err.Token.Line)
-	HadRuntimeError = true
+func (in *Interpreter) reportRuntimeError(file string, err *Error) {
+	if err.Code == "" {
+		err.Code = CodeRuntime
+	}
+	d := newTokenDiagnostic(PhaseRuntime, err)
+	if d.Span.File == "" {
+		d.Span.File = file
+	}
+	for _, frame := range err.Trace {
+		if frame.Span.File == "" {
+			frame.Span.File = file
+		}
+		d.Trace = append(d.Trace, frame)
+	}
+	in.report(d)
 }
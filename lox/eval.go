@@ -2,53 +2,67 @@ package lox
 
 import (
 	"errors"
-	"fmt"
 	"golox/lox/expr"
 	"golox/lox/tok"
 )
 
-func Eval(ex expr.Expr) (any, error) {
+func (in *Interpreter) Eval(ex expr.Expr) (any, error) {
+	in.depth++
+	value, err := in.eval(ex)
+	in.depth--
+	return value, err
+}
+
+func (in *Interpreter) eval(ex expr.Expr) (any, error) {
 	switch e := ex.(type) {
 	case *expr.Binary:
-		return evalBinary(e)
+		return in.evalBinary(e)
 	case *expr.Grouping:
-		return Eval(e.Expression)
+		return in.Eval(e.Expression)
 	case *expr.Literal:
 		return e.Value, nil
 	case *expr.Logical:
-		return evalLogical(e)
+		return in.evalLogical(e)
 	case *expr.Unary:
-		return evalUnary(e)
+		return in.evalUnary(e)
 	case *expr.Variable:
-		return lookupVariable(e.Name, e)
+		return in.lookupVariable(e.Name, e)
 	case *expr.Assign:
-		return evalAssign(e)
+		return in.evalAssign(e)
 	case *expr.Call:
-		return evalCall(e)
+		return in.evalCall(e)
 	case *expr.Get:
-		return evalGet(e)
+		return in.evalGet(e)
 	case *expr.Set:
-		return evalSet(e)
+		return in.evalSet(e)
 	case *expr.This:
-		return lookupVariable(e.Keyword, e)
+		return in.lookupVariable(e.Keyword, e)
 	case *expr.Super:
-		return evalSuper(e)
+		return in.evalSuper(e)
+	case *expr.List:
+		return in.evalList(e)
+	case *expr.Map:
+		return in.evalMap(e)
+	case *expr.Index:
+		return in.evalIndex(e)
+	case *expr.SetIndex:
+		return in.evalSetIndex(e)
 	default:
 		return nil, errors.New("unhandled expression type")
 	}
 }
 
-func lookupVariable(name *tok.Token, e expr.Expr) (any, error) {
-	distance, ok := depthMap[e]
+func (in *Interpreter) lookupVariable(name *tok.Token, e expr.Expr) (any, error) {
+	local, ok := in.locals[e]
 	if ok {
-		return currentEnv.GetAt(distance, name.Lexeme), nil
+		return in.env.GetAt(local.depth, local.slot), nil
 	} else {
-		return globalEnv.Get(name)
+		return in.globals.Get(name)
 	}
 }
 
-func evalUnary(e *expr.Unary) (any, error) {
-	right, err := Eval(e.Right)
+func (in *Interpreter) evalUnary(e *expr.Unary) (any, error) {
+	right, err := in.Eval(e.Right)
 	if err != nil {
 		return nil, err
 	}
@@ -66,12 +80,12 @@ func evalUnary(e *expr.Unary) (any, error) {
 	}
 }
 
-func evalBinary(e *expr.Binary) (any, error) {
-	left, err := Eval(e.Left)
+func (in *Interpreter) evalBinary(e *expr.Binary) (any, error) {
+	left, err := in.Eval(e.Left)
 	if err != nil {
 		return nil, err
 	}
-	right, err := Eval(e.Right)
+	right, err := in.Eval(e.Right)
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +135,7 @@ func evalBinary(e *expr.Binary) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		return float64(int(left.(float64)) % int(right.(float64))), nil
+		return modulo(e.Operator, left.(float64), right.(float64))
 	case tok.Star:
 		err = checkNumberOperands(e.Operator, left, right)
 		if err != nil {
@@ -143,16 +157,16 @@ func evalBinary(e *expr.Binary) (any, error) {
 	}
 }
 
-func evalAssign(e *expr.Assign) (any, error) {
-	value, err := Eval(e.Value)
+func (in *Interpreter) evalAssign(e *expr.Assign) (any, error) {
+	value, err := in.Eval(e.Value)
 	if err != nil {
 		return nil, err
 	}
-	distance, ok := depthMap[e]
+	local, ok := in.locals[e]
 	if ok {
-		currentEnv.AssignAt(distance, e.Name, value)
+		in.env.AssignAt(local.depth, local.slot, value)
 	} else {
-		err = globalEnv.Assign(e.Name, value)
+		err = in.globals.Assign(e.Name, value)
 		if err != nil {
 			return nil, err
 		}
@@ -161,8 +175,8 @@ func evalAssign(e *expr.Assign) (any, error) {
 	return value, nil
 }
 
-func evalLogical(e *expr.Logical) (any, error) {
-	left, err := Eval(e.Left)
+func (in *Interpreter) evalLogical(e *expr.Logical) (any, error) {
+	left, err := in.Eval(e.Left)
 	if err != nil {
 		return nil, err
 	}
@@ -175,18 +189,18 @@ func evalLogical(e *expr.Logical) (any, error) {
 			return left, nil
 		}
 	}
-	return Eval(e.Right)
+	return in.Eval(e.Right)
 }
 
-func evalCall(e *expr.Call) (any, error) {
-	callee, err := Eval(e.Callee)
+func (in *Interpreter) evalCall(e *expr.Call) (any, error) {
+	callee, err := in.Eval(e.Callee)
 	if err != nil {
 		return nil, err
 	}
 
 	var arguments []any
 	for _, a := range e.Arguments {
-		arg, err := Eval(a)
+		arg, err := in.Eval(a)
 		if err != nil {
 			return nil, err
 		}
@@ -201,61 +215,84 @@ func evalCall(e *expr.Call) (any, error) {
 		}
 	}
 
-	if len(arguments) != f.Arity() {
-		return nil, &Error{
-			Token: e.Paren,
-			Message: fmt.Sprintf("Expected %d arguments but got %d",
-				f.Arity(), len(arguments)),
-		}
+	if err := checkArity(f.Arity(), len(arguments), e.Paren); err != nil {
+		return nil, err
 	}
 
-	return f.Call(arguments)
+	return in.call(f, arguments, e.Paren)
 }
 
-func evalGet(e *expr.Get) (any, error) {
-	object, err := Eval(e.Object)
-	if err != nil {
-		return nil, err
+// call calls f, pushing a frame for the traceback. paren is nil when the
+// call comes from Go rather than from Lox code.
+func (in *Interpreter) call(f Callable, arguments []any, paren *tok.Token) (any, error) {
+	// Only calls to Lox code get a frame in tracebacks. Errors from native
+	// functions are reported at the call site.
+	var name string
+	switch c := f.(type) {
+	case *Function:
+		name = c.declaration.Name.Lexeme
+	case *Class:
+		name = c.name
+	default:
+		result, err := f.Call(in, arguments)
+		if paren == nil {
+			return result, err
+		}
+		return result, nativeError(err, paren)
 	}
 
-	instance, ok := object.(*Instance)
-	if !ok {
-		return nil, &Error{
-			Token:   e.Name,
-			Message: "Only instances have properties",
+	// The script itself counts as a frame, as it does in the VM. Deeply
+	// nested code uses more of the Go stack for each call, so the depth
+	// is limited too.
+	if len(in.frames)+1 == maxFrames || in.depth >= maxDepth {
+		if paren == nil {
+			return nil, errors.New("Stack overflow")
 		}
+		return nil, &Error{Token: paren, Message: "Stack overflow"}
 	}
+	in.frames = append(in.frames, callFrame{name: name, call: paren, env: in.env})
+	result, err := f.Call(in, arguments)
+	if runtimeError, ok := err.(*Error); ok && runtimeError.Trace == nil {
+		runtimeError.Trace = in.stackTrace(runtimeError)
+	}
+	in.frames = in.frames[:len(in.frames)-1]
+	return result, err
+}
 
-	return instance.Get(e.Name)
+func (in *Interpreter) evalGet(e *expr.Get) (any, error) {
+	object, err := in.Eval(e.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	return getProperty(object, e.Name)
 }
 
-func evalSet(e *expr.Set) (any, error) {
-	object, err := Eval(e.Object)
+func (in *Interpreter) evalSet(e *expr.Set) (any, error) {
+	object, err := in.Eval(e.Object)
 	if err != nil {
 		return nil, err
 	}
 
-	instance, ok := object.(*Instance)
-	if !ok {
+	if _, ok := object.(PropertyAccessor); !ok {
 		return nil, &Error{
 			Token:   e.Name,
 			Message: "Only instances have fields",
 		}
 	}
 
-	value, err := Eval(e.Value)
+	value, err := in.Eval(e.Value)
 	if err != nil {
 		return nil, err
 	}
 
-	instance.Set(e.Name, value)
-	return nil, nil
+	return value, setProperty(object, e.Name, value)
 }
 
-func evalSuper(e *expr.Super) (any, error) {
-	distance := depthMap[e]
-	superclass := currentEnv.GetAt(distance, "super").(*Class)
-	object := currentEnv.GetAt(distance-1, "this").(*Instance)
+func (in *Interpreter) evalSuper(e *expr.Super) (any, error) {
+	distance := in.locals[e].depth
+	superclass := in.env.GetAt(distance, 0).(*Class)
+	object := in.env.GetAt(distance-1, 0).(*Instance)
 	method := superclass.FindMethod(e.Method.Lexeme)
 
 	if method == nil {
@@ -268,6 +305,69 @@ func evalSuper(e *expr.Super) (any, error) {
 	return method.Bind(object), nil
 }
 
+func (in *Interpreter) evalList(e *expr.List) (any, error) {
+	elements := make([]any, 0, len(e.Elements))
+	for _, element := range e.Elements {
+		value, err := in.Eval(element)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, value)
+	}
+	return NewList(elements), nil
+}
+
+func (in *Interpreter) evalMap(e *expr.Map) (any, error) {
+	m := NewMap()
+	for i := range e.Keys {
+		key, err := in.Eval(e.Keys[i])
+		if err != nil {
+			return nil, err
+		}
+		value, err := in.Eval(e.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Put(key, value); err != nil {
+			return nil, nativeError(err, e.Brace)
+		}
+	}
+	return m, nil
+}
+
+func (in *Interpreter) evalIndex(e *expr.Index) (any, error) {
+	object, err := in.Eval(e.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := in.Eval(e.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	return getIndex(object, index, e.Bracket)
+}
+
+func (in *Interpreter) evalSetIndex(e *expr.SetIndex) (any, error) {
+	object, err := in.Eval(e.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := in.Eval(e.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := in.Eval(e.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return value, setIndex(object, index, e.Bracket, value)
+}
+
 func checkNumberOperand(tok *tok.Token, operand any) error {
 	if !isNumber(operand) {
 		return &Error{Token: tok, Message: "operand must be a number"}
@@ -285,6 +385,14 @@ func checkNumberOperands(tok *tok.Token, left any, right any) error {
 	return nil
 }
 
+// modulo returns the remainder of dividing the integer parts of a and b.
+func modulo(operator *tok.Token, a float64, b float64) (any, error) {
+	if int(b) == 0 {
+		return nil, &Error{Token: operator, Message: "Modulo by zero"}
+	}
+	return float64(int(a) % int(b)), nil
+}
+
 func isNumber(value any) bool {
 	_, ok := value.(float64)
 	return ok
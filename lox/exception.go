@@ -0,0 +1,74 @@
+package lox
+
+import (
+	"errors"
+	"golox/lox/tok"
+)
+
+// ErrorObject is the value caught by a catch clause when the error was
+// raised by the interpreter or a native function rather than thrown by a
+// throw statement. Scripts can create their own with Error(message).
+type ErrorObject struct {
+	message string
+	token   *tok.Token
+	// trace is the traceback from when the error was first caught, which
+	// is kept so that rethrowing the error doesn't rebuild it.
+	trace []StackFrame
+}
+
+func NewErrorObject(message string) *ErrorObject {
+	return &ErrorObject{message: message}
+}
+
+func (e *ErrorObject) String() string {
+	return e.message
+}
+
+func (e *ErrorObject) GetProperty(name string) (any, error) {
+	switch name {
+	case "message":
+		return e.message, nil
+	case "line":
+		if e.token == nil {
+			return nil, nil
+		}
+		return float64(e.token.Line), nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+func (e *ErrorObject) SetProperty(name string, value any) error {
+	return errors.New("Can't set properties on an error")
+}
+
+// throw returns the runtime error that unwinds the stack when a throw
+// statement throws value. Thrown error objects keep the line they were
+// first thrown from, and their traceback if they have been caught.
+func throw(value any, keyword *tok.Token) error {
+	switch v := value.(type) {
+	case nil:
+		return &Error{Token: keyword, Message: "Can't throw nil"}
+	case *ErrorObject:
+		if v.token == nil {
+			v.token = keyword
+		}
+		return &Error{Token: v.token, Message: v.message, Value: v, Trace: v.trace}
+	default:
+		return &Error{
+			Token:   keyword,
+			Message: "Uncaught exception: " + stringify(v),
+			Value:   v,
+		}
+	}
+}
+
+// caughtValue returns the value bound by a catch clause that catches err.
+func caughtValue(err *Error) any {
+	if err.Value != nil {
+		if e, ok := err.Value.(*ErrorObject); ok && e.trace == nil {
+			e.trace = err.Trace
+		}
+		return err.Value
+	}
+	return &ErrorObject{message: err.Message, token: err.Token, trace: err.Trace}
+}
@@ -0,0 +1,71 @@
+package lox
+
+import "testing"
+
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`try { print 1 + "a"; } catch (e) { print e.message; print e.line; }`,
+			"operands should be numbers or strings\n1\n"},
+		{`try { throw "boom"; } catch (e) { print e; }`, "boom\n"},
+		{"try { throw [1, 2]; } catch (e) { print e; }", "[1, 2]\n"},
+		{`try {
+  throw Error("custom");
+} catch (e) {
+  print e.message;
+  print e.line;
+  print e;
+}`, "custom\n2\ncustom\n"},
+		{`fun f(n) { if (n == 0) throw Error("deep"); return f(n - 1); }
+try { f(5); } catch (e) { print "caught " + e.message; }`, "caught deep\n"},
+		{`try { print "body"; } finally { print "finally"; }`, "body\nfinally\n"},
+		{`try { throw 1; } catch (e) { print "catch"; } finally { print "finally"; }`, "catch\nfinally\n"},
+		{`fun f() { try { return "try"; } finally { print "finally"; } }
+print f();`, "finally\ntry\n"},
+		{`fun f() { try { throw "x"; } finally { return "finally wins"; } }
+print f();`, "finally wins\n"},
+		{`try {
+  try { throw "inner"; } finally { print "inner finally"; }
+} catch (e) {
+  print "outer caught " + e;
+}`, "inner finally\nouter caught inner\n"},
+		{`try { try { throw "a"; } catch (e) { throw "b"; } finally { print "f"; } } catch (e) { print e; }`,
+			"f\nb\n"},
+		// A rethrown error keeps the line it was first raised on.
+		{`fun rethrow() {
+  try { [].pop(); } catch (e) { throw e; }
+}
+try { rethrow(); } catch (e) { print e.message; print e.line; }`, "Can't pop from an empty list\n2\n"},
+	}
+	for _, test := range tests {
+		expectOutput(t, test.source, test.want)
+	}
+}
+
+func TestExceptionErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`throw "boom";`, "Uncaught exception: boom"},
+		{"throw 1.5;", "Uncaught exception: 1.5"},
+		{`throw Error("custom");`, "custom"},
+		{"throw nil;", "Can't throw nil"},
+		{`try { throw "x"; } catch (e) {} print e;`, "Undefined variable 'e'"},
+		{`try { throw "x"; } finally { print "finally"; }`, "Uncaught exception: x"},
+		{`try { throw "x"; } catch (e) { e.message = "y"; }`, "Only instances have fields"},
+		{`try { throw Error("x"); } catch (e) { e.message = "y"; }`, "Can't set properties on an error"},
+	}
+	for _, test := range tests {
+		expectError(t, test.source, test.want)
+	}
+}
+
+func TestUncaughtExceptionRunsFinally(t *testing.T) {
+	stdout, _ := runBoth(t, `try { throw "x"; } finally { print "finally"; }`)
+	if stdout != "finally\n" {
+		t.Errorf("output = %q, want %q", stdout, "finally\n")
+	}
+}
@@ -5,101 +5,174 @@ import (
 	"golox/lox/stmt"
 )
 
-func Exec(st stmt.Stmt) error {
+func (in *Interpreter) Exec(st stmt.Stmt) error {
+	in.depth++
+	err := in.exec(st)
+	in.depth--
+	return err
+}
+
+func (in *Interpreter) exec(st stmt.Stmt) error {
+	if in.statementHook != nil {
+		if err := in.statementHook(st, stmt.FirstToken(st)); err != nil {
+			return err
+		}
+	}
+	if in.profiler != nil {
+		in.profiler.hit(st)
+	}
+
 	switch s := st.(type) {
 	case *stmt.Print:
-		val, err := Eval(s.Expression)
+		val, err := in.Eval(s.Expression)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("%v\n", val)
+		fmt.Fprintln(in.stdout, stringify(val))
 		return nil
 	case *stmt.Expression:
-		_, err := Eval(s.Expression)
+		_, err := in.Eval(s.Expression)
 		return err
 	case *stmt.If:
-		return execIf(s)
+		return in.execIf(s)
 	case *stmt.While:
-		return execWhile(s)
+		return in.execWhile(s)
 	case *stmt.Var:
-		return execVar(s)
+		return in.execVar(s)
 	case *stmt.Block:
-		return execBlock(s.Statements, NewEnvironment(currentEnv))
+		return in.execBlock(s.Statements, NewEnvironment(in.env))
 	case *stmt.Function:
-		return execFunction(s)
+		return in.execFunction(s)
 	case *stmt.Return:
-		return execReturn(s)
+		return in.execReturn(s)
 	case *stmt.Class:
-		return execClass(s)
+		return in.execClass(s)
+	case *stmt.Import:
+		module, err := in.importModule(s)
+		if err != nil {
+			return err
+		}
+		in.define(s.Name.Lexeme, module)
+		return nil
+	case *stmt.Export:
+		return in.Exec(s.Declaration)
+	case *stmt.Throw:
+		return in.execThrow(s)
+	case *stmt.Try:
+		return in.execTry(s)
+	case *stmt.Break:
+		return &Break{}
+	case *stmt.Continue:
+		return &Continue{}
 	default:
 		return fmt.Errorf("unhandled statement %v", st)
 	}
 }
 
-func execIf(s *stmt.If) error {
-	condition, err := Eval(s.Condition)
+func (in *Interpreter) execIf(s *stmt.If) error {
+	condition, err := in.Eval(s.Condition)
 	if err != nil {
 		return err
 	}
 	if isTruthy(condition) {
-		return Exec(s.ThenBranch)
+		return in.Exec(s.ThenBranch)
 	} else if s.ElseBranch != nil {
-		return Exec(s.ElseBranch)
+		return in.Exec(s.ElseBranch)
 	}
 	return nil
 }
 
-func execWhile(s *stmt.While) error {
+func (in *Interpreter) execWhile(s *stmt.While) error {
 	for {
-		condition, err := Eval(s.Condition)
+		condition, err := in.Eval(s.Condition)
 		if err != nil {
 			return err
 		}
 		if !isTruthy(condition) {
 			return nil
 		}
-		if err = Exec(s.Body); err != nil {
+		err = in.Exec(s.Body)
+		switch err.(type) {
+		case *Break:
+			return nil
+		case *Continue:
+			err = nil
+		}
+		if err != nil {
 			return err
 		}
+		if s.Increment != nil {
+			if _, err = in.Eval(s.Increment); err != nil {
+				return err
+			}
+		}
 	}
 }
 
-func execVar(s *stmt.Var) error {
+func (in *Interpreter) execThrow(s *stmt.Throw) error {
+	value, err := in.Eval(s.Value)
+	if err != nil {
+		return err
+	}
+	return throw(value, s.Keyword)
+}
+
+func (in *Interpreter) execTry(s *stmt.Try) error {
+	err := in.execBlock(s.Body.Statements, NewEnvironment(in.env))
+	if runtimeError, ok := err.(*Error); ok && s.Catch != nil {
+		env := NewEnvironment(in.env)
+		in.defineLocal(env, s.CatchName.Lexeme, caughtValue(runtimeError))
+		err = in.execBlock(s.Catch.Statements, env)
+	}
+
+	// The finally block runs however the try statement completes, including
+	// by return, break or continue, and an error or jump from the finally
+	// block itself takes precedence.
+	if s.Finally != nil {
+		if finallyErr := in.execBlock(s.Finally.Statements, NewEnvironment(in.env)); finallyErr != nil {
+			return finallyErr
+		}
+	}
+
+	return err
+}
+
+func (in *Interpreter) execVar(s *stmt.Var) error {
 	var value any
 	var err error
 	if s.Initializer != nil {
-		value, err = Eval(s.Initializer)
+		value, err = in.Eval(s.Initializer)
 		if err != nil {
 			return err
 		}
 	}
-	currentEnv.Define(s.Name.Lexeme, value)
+	in.define(s.Name.Lexeme, value)
 	return nil
 }
 
-func execBlock(statements []stmt.Stmt, env *Environment) error {
-	previousEnv := currentEnv
-	currentEnv = env
+func (in *Interpreter) execBlock(statements []stmt.Stmt, env *Environment) error {
+	previousEnv := in.env
+	in.env = env
 	for _, s := range statements {
-		if err := Exec(s); err != nil {
-			currentEnv = previousEnv
+		if err := in.Exec(s); err != nil {
+			in.env = previousEnv
 			return err
 		}
 	}
-	currentEnv = previousEnv
+	in.env = previousEnv
 	return nil
 }
 
-func execFunction(s *stmt.Function) error {
-	currentEnv.Define(s.Name.Lexeme, NewFunction(s, currentEnv, false))
+func (in *Interpreter) execFunction(s *stmt.Function) error {
+	in.define(s.Name.Lexeme, NewFunction(s, in.env, in.globals, false))
 	return nil
 }
 
-func execReturn(s *stmt.Return) error {
+func (in *Interpreter) execReturn(s *stmt.Return) error {
 	var result any
 	var err error
 	if s.Value != nil {
-		result, err = Eval(s.Value)
+		result, err = in.Eval(s.Value)
 		if err != nil {
 			return err
 		}
@@ -107,11 +180,11 @@ func execReturn(s *stmt.Return) error {
 	return &Return{Value: result}
 }
 
-func execClass(s *stmt.Class) error {
+func (in *Interpreter) execClass(s *stmt.Class) error {
 	var superclass *Class
 
 	if s.Superclass != nil {
-		sc, err := Eval(s.Superclass)
+		sc, err := in.Eval(s.Superclass)
 		if err != nil {
 			return err
 		}
@@ -124,23 +197,39 @@ func execClass(s *stmt.Class) error {
 		}
 	}
 
-	currentEnv.Define(s.Name.Lexeme, nil)
-
 	if s.Superclass != nil {
-		currentEnv = NewEnvironment(currentEnv)
-		currentEnv.Define("super", superclass)
+		in.env = NewEnvironment(in.env)
+		in.defineLocal(in.env, "super", superclass)
 	}
 
-	methods := make(map[string]*Function)
+	methods := make(map[string]Method)
 	for _, m := range s.Methods {
-		methods[m.Name.Lexeme] = NewFunction(m, currentEnv,
+		methods[m.Name.Lexeme] = NewFunction(m, in.env, in.globals,
 			m.Name.Lexeme == "init")
 	}
 	class := NewClass(s.Name.Lexeme, superclass, methods)
 
 	if s.Superclass != nil {
-		currentEnv = currentEnv.enclosing
+		in.env = in.env.enclosing
+	}
+
+	in.define(s.Name.Lexeme, class)
+	return nil
+}
+
+func (in *Interpreter) define(name string, value any) {
+	if in.env == nil {
+		in.globals.Define(name, value)
+	} else {
+		in.defineLocal(in.env, name, value)
 	}
+}
 
-	return currentEnv.Assign(s.Name, class)
+// defineLocal defines the next slot of env, recording its name only if the
+// debugger might ask for it.
+func (in *Interpreter) defineLocal(env *Environment, name string, value any) {
+	if in.statementHook != nil {
+		env.names = append(env.names, name)
+	}
+	env.Define(value)
 }
@@ -20,6 +20,10 @@ func (e *Get) expr()      {}
 func (e *Set) expr()      {}
 func (e *This) expr()     {}
 func (e *Super) expr()    {}
+func (e *List) expr()     {}
+func (e *Index) expr()    {}
+func (e *SetIndex) expr() {}
+func (e *Map) expr()      {}
 
 type Binary struct {
 	Left     Expr
@@ -28,10 +32,14 @@ type Binary struct {
 }
 
 type Grouping struct {
+	Paren      *tok.Token
 	Expression Expr
 }
 
+// Literal is a literal value. Token is nil for literals that the parser
+// supplies itself, such as the condition of a for loop with none.
 type Literal struct {
+	Token *tok.Token
 	Value any
 }
 
@@ -80,3 +88,68 @@ type Super struct {
 	Keyword *tok.Token
 	Method  *tok.Token
 }
+
+type List struct {
+	Bracket  *tok.Token
+	Elements []Expr
+}
+
+// Map is a map literal. Keys[i] is the key for Values[i].
+type Map struct {
+	Brace  *tok.Token
+	Keys   []Expr
+	Values []Expr
+}
+
+type Index struct {
+	Object  Expr
+	Bracket *tok.Token
+	Index   Expr
+}
+
+type SetIndex struct {
+	Object  Expr
+	Bracket *tok.Token
+	Index   Expr
+	Value   Expr
+}
+
+// FirstToken returns the leftmost token of an expression, or nil if it has
+// none.
+func FirstToken(expr Expr) *tok.Token {
+	switch e := expr.(type) {
+	case *Binary:
+		return FirstToken(e.Left)
+	case *Grouping:
+		return e.Paren
+	case *Literal:
+		return e.Token
+	case *Unary:
+		return e.Operator
+	case *Variable:
+		return e.Name
+	case *Assign:
+		return e.Name
+	case *Logical:
+		return FirstToken(e.Left)
+	case *Call:
+		return FirstToken(e.Callee)
+	case *Get:
+		return FirstToken(e.Object)
+	case *Set:
+		return FirstToken(e.Object)
+	case *This:
+		return e.Keyword
+	case *Super:
+		return e.Keyword
+	case *List:
+		return e.Bracket
+	case *Map:
+		return e.Brace
+	case *Index:
+		return FirstToken(e.Object)
+	case *SetIndex:
+		return FirstToken(e.Object)
+	}
+	return nil
+}
@@ -2,9 +2,12 @@ package expr
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
+// PrintExpr formats an expression as an S-expression, for debugging the
+// parser.
 func PrintExpr(expr Expr) string {
 	switch e := expr.(type) {
 	case *Binary:
@@ -12,15 +15,46 @@ func PrintExpr(expr Expr) string {
 	case *Grouping:
 		return parenthesize("group", e.Expression)
 	case *Literal:
-		if e.Value == nil {
+		switch v := e.Value.(type) {
+		case nil:
 			return "nil"
-		} else {
-			return fmt.Sprintf("%v", e.Value)
+		case string:
+			return strconv.Quote(v)
+		default:
+			return fmt.Sprintf("%v", v)
 		}
 	case *Unary:
 		return parenthesize(e.Operator.Lexeme, e.Right)
+	case *Variable:
+		return e.Name.Lexeme
+	case *Assign:
+		return parenthesize("= "+e.Name.Lexeme, e.Value)
+	case *Logical:
+		return parenthesize(e.Operator.Lexeme, e.Left, e.Right)
+	case *Call:
+		return parenthesize("call", append([]Expr{e.Callee}, e.Arguments...)...)
+	case *Get:
+		return fmt.Sprintf("(. %s %s)", PrintExpr(e.Object), e.Name.Lexeme)
+	case *Set:
+		return fmt.Sprintf("(=. %s %s %s)", PrintExpr(e.Object), e.Name.Lexeme, PrintExpr(e.Value))
+	case *This:
+		return "this"
+	case *Super:
+		return "(super " + e.Method.Lexeme + ")"
+	case *List:
+		return parenthesize("list", e.Elements...)
+	case *Map:
+		var es []Expr
+		for i := range e.Keys {
+			es = append(es, e.Keys[i], e.Values[i])
+		}
+		return parenthesize("map", es...)
+	case *Index:
+		return parenthesize("[]", e.Object, e.Index)
+	case *SetIndex:
+		return parenthesize("[]=", e.Object, e.Index, e.Value)
 	default:
-		return ""
+		return fmt.Sprintf("(unknown %T)", expr)
 	}
 }
 
@@ -0,0 +1,240 @@
+package lox
+
+import (
+	"golox/lox/stmt"
+	"golox/lox/tok"
+	"strings"
+)
+
+// Format returns source laid out in the canonical style: one statement per
+// line, blocks indented by two spaces with the opening brace on the same
+// line, single spaces around binary operators and after commas, and at
+// most one blank line in a row. Comments are kept. If source has errors,
+// Format returns them instead.
+func Format(file string, source string) (string, []*Diagnostic) {
+	statements, diagnostics := parse(file, source)
+	if len(diagnostics) > 0 {
+		return "", diagnostics
+	}
+
+	scanner := NewScanner(source)
+	tokens := scanner.ScanTokens()
+	f := &formatter{}
+	f.format(tokens[:len(tokens)-1], scanner.Comments())
+	formatted := f.sb.String()
+
+	// The formatter only changes the space between tokens, so this should
+	// never fail, but a formatter that changes the meaning of a program
+	// would be much worse than one that refuses to run.
+	reparsed, diagnostics := parse(file, formatted)
+	if len(diagnostics) > 0 || dumpAST(reparsed) != dumpAST(statements) {
+		return "", []*Diagnostic{{
+			Severity: SeverityError,
+			Phase:    PhaseParse,
+			Code:     CodeFormat,
+			Message:  "Formatting would change the meaning of the program",
+			Span:     Span{File: file, Line: 1},
+		}}
+	}
+	return formatted, nil
+}
+
+func dumpAST(statements []stmt.Stmt) string {
+	sb := &strings.Builder{}
+	for _, s := range statements {
+		sb.WriteString(stmt.PrintStmt(s))
+		sb.WriteRune('\n')
+	}
+	return sb.String()
+}
+
+type formatter struct {
+	sb     strings.Builder
+	indent int
+	// parens counts the open parentheses and brackets, so that the
+	// semicolons in a for loop's clauses don't end the line.
+	parens int
+	// braces records whether each open brace began a block or a map.
+	braces []bool
+	// prev is the previous token, and last is the previous token or
+	// comment.
+	prev *tok.Token
+	last *tok.Token
+	// prevUnary is true if prev is a unary operator, and prevBlockEnd is
+	// true if it is the closing brace of a block.
+	prevUnary    bool
+	prevBlockEnd bool
+	// newline is true if the next token must start a new line.
+	newline bool
+}
+
+// format writes tokens and comments, which must both be in source order.
+func (f *formatter) format(tokens []*tok.Token, comments []*tok.Token) {
+	for len(tokens) > 0 || len(comments) > 0 {
+		if len(comments) > 0 && (len(tokens) == 0 || comments[0].Offset < tokens[0].Offset) {
+			f.comment(comments[0])
+			comments = comments[1:]
+		} else {
+			f.token(tokens[0])
+			tokens = tokens[1:]
+		}
+	}
+	if f.sb.Len() > 0 {
+		f.sb.WriteRune('\n')
+	}
+}
+
+func (f *formatter) comment(c *tok.Token) {
+	text := strings.TrimRight(c.Lexeme, " \t\r")
+	if f.last != nil && c.Line == f.last.EndLine {
+		f.sb.WriteRune(' ')
+	} else {
+		f.breakLine(c)
+	}
+	f.sb.WriteString(text)
+	f.last = c
+	f.newline = true
+}
+
+func (f *formatter) token(t *tok.Token) {
+	isBlock := false
+	switch t.Type {
+	case tok.LeftBrace:
+		isBlock = !f.startsMap()
+	case tok.RightBrace:
+		isBlock = f.braces[len(f.braces)-1]
+		f.braces = f.braces[:len(f.braces)-1]
+		if isBlock {
+			f.indent--
+			// An empty block stays on one line.
+			f.newline = f.prev.Type != tok.LeftBrace || f.last != f.prev
+		}
+	case tok.Else, tok.Catch, tok.Finally:
+		if f.prevBlockEnd && f.last == f.prev {
+			f.newline = false
+		}
+	}
+	unary := t.Type == tok.Bang ||
+		t.Type == tok.Minus && (f.prev == nil || !f.endsOperand(f.prev))
+
+	if f.newline {
+		f.breakLine(t)
+	} else if f.prev != nil && f.spaceBefore(t) {
+		f.sb.WriteRune(' ')
+	}
+	f.sb.WriteString(t.Lexeme)
+
+	f.newline = false
+	switch t.Type {
+	case tok.LeftParen, tok.LeftBracket:
+		f.parens++
+	case tok.RightParen, tok.RightBracket:
+		f.parens--
+	case tok.LeftBrace:
+		f.braces = append(f.braces, isBlock)
+		if isBlock {
+			f.indent++
+			f.newline = true
+		}
+	case tok.RightBrace:
+		f.newline = isBlock
+	case tok.Semicolon:
+		f.newline = f.parens == 0
+	}
+	f.prev = t
+	f.last = t
+	f.prevUnary = unary
+	f.prevBlockEnd = t.Type == tok.RightBrace && isBlock
+}
+
+// breakLine starts a new line for t, keeping a blank line from the source
+// except at the start or end of a block.
+func (f *formatter) breakLine(t *tok.Token) {
+	if f.last == nil {
+		return
+	}
+	f.sb.WriteRune('\n')
+	afterOpen := f.prev != nil && f.prev.Type == tok.LeftBrace && f.last == f.prev
+	if t.Line-f.last.EndLine > 1 && !afterOpen && t.Type != tok.RightBrace {
+		f.sb.WriteRune('\n')
+	}
+	indent := f.indent
+	if !f.atStatementStart() {
+		// A comment broke the line in the middle of a statement.
+		indent++
+	}
+	f.sb.WriteString(strings.Repeat("  ", indent))
+}
+
+func (f *formatter) atStatementStart() bool {
+	if f.prev == nil || f.prevBlockEnd {
+		return true
+	}
+	switch f.prev.Type {
+	case tok.Semicolon:
+		return f.parens == 0
+	case tok.LeftBrace:
+		return f.braces[len(f.braces)-1]
+	}
+	return false
+}
+
+// startsMap reports whether a left brace after prev begins a map literal
+// rather than a block.
+func (f *formatter) startsMap() bool {
+	if f.prev == nil || f.prevBlockEnd {
+		return false
+	}
+	switch f.prev.Type {
+	case tok.Identifier, tok.RightParen, tok.Else, tok.Try, tok.Finally, tok.Semicolon:
+		return false
+	case tok.LeftBrace:
+		// A brace can start a block, or a key in a map literal.
+		return !f.braces[len(f.braces)-1]
+	}
+	return true
+}
+
+// endsOperand reports whether t can be the last token of an operand, so
+// that a following minus is a binary operator.
+func (f *formatter) endsOperand(t *tok.Token) bool {
+	switch t.Type {
+	case tok.Identifier, tok.String, tok.Number, tok.True, tok.False, tok.Nil,
+		tok.This, tok.RightParen, tok.RightBracket:
+		return true
+	case tok.RightBrace:
+		return !f.prevBlockEnd
+	}
+	return false
+}
+
+func (f *formatter) spaceBefore(t *tok.Token) bool {
+	// A block's left brace is always followed by a new line unless the
+	// block is empty, so a brace here is either that or the start of a map.
+	switch f.prev.Type {
+	case tok.LeftParen, tok.LeftBracket, tok.LeftBrace, tok.Dot:
+		return false
+	}
+	if f.prevUnary {
+		// "- -x" would read as a decrement if the operators were joined.
+		return f.prev.Type == tok.Minus && t.Type == tok.Minus
+	}
+
+	switch t.Type {
+	case tok.RightParen, tok.RightBracket, tok.Comma, tok.Semicolon, tok.Dot, tok.Colon:
+		return false
+	case tok.RightBrace:
+		// The closing brace of a map. Blocks always end on a new line.
+		return false
+	case tok.LeftParen:
+		// A call or a function's parameter list.
+		switch f.prev.Type {
+		case tok.Identifier, tok.RightParen, tok.RightBracket:
+			return false
+		}
+	case tok.LeftBracket:
+		// An index rather than a list literal.
+		return !f.endsOperand(f.prev)
+	}
+	return true
+}
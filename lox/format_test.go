@@ -0,0 +1,122 @@
+package lox
+
+import "testing"
+
+var formatTests = []struct {
+	name   string
+	source string
+	want   string
+}{
+	{
+		name:   "unary operators",
+		source: "print - -1;print !!true;print -(-1);var a=1;print a- -a;",
+		want:   "print - -1;\nprint !!true;\nprint -(-1);\nvar a = 1;\nprint a - -a;\n",
+	},
+	{
+		name:   "functions and blank lines",
+		source: "fun   f(a,b){return a+b;}\n\n\n\nprint f(1,2);",
+		want:   "fun f(a, b) {\n  return a + b;\n}\n\nprint f(1, 2);\n",
+	},
+	{
+		name:   "classes",
+		source: "class A{init(x){this.x=x;}m(){return [1,2][0]+this.x;}}class B<A{m(){return super.m();}}print B(2).m();",
+		want: `class A {
+  init(x) {
+    this.x = x;
+  }
+  m() {
+    return [1, 2][0] + this.x;
+  }
+}
+class B < A {
+  m() {
+    return super.m();
+  }
+}
+print B(2).m();
+`,
+	},
+	{
+		name:   "maps and loops",
+		source: `var m={"a":1,"b":[1,2]};for(var i=0;i<3;i=i+1){if(i==1)continue;else print m["b"];}while(false){}`,
+		want: `var m = {"a": 1, "b": [1, 2]};
+for (var i = 0; i < 3; i = i + 1) {
+  if (i == 1) continue;
+  else print m["b"];
+}
+while (false) {}
+`,
+	},
+	{
+		name:   "comments and exceptions",
+		source: "// top\nvar x = 1; // trailing\n{\n\n// inside\nprint x;\n\n}\ntry{throw 1;}catch(e){print e;}finally{print 2;}",
+		want: `// top
+var x = 1; // trailing
+{
+  // inside
+  print x;
+}
+try {
+  throw 1;
+} catch (e) {
+  print e;
+} finally {
+  print 2;
+}
+`,
+	},
+}
+
+func TestFormat(t *testing.T) {
+	for _, test := range formatTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, diagnostics := Format("test.lox", test.source)
+			if len(diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %v", diagnostics)
+			}
+			if got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatIsIdempotent(t *testing.T) {
+	for _, test := range formatTests {
+		t.Run(test.name, func(t *testing.T) {
+			once, _ := Format("test.lox", test.source)
+			twice, diagnostics := Format("test.lox", once)
+			if len(diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %v", diagnostics)
+			}
+			if twice != once {
+				t.Errorf("formatting twice gave:\n%s\nonce gave:\n%s", twice, once)
+			}
+		})
+	}
+}
+
+// TestFormatRoundTrip checks that formatted programs behave like the
+// originals.
+func TestFormatRoundTrip(t *testing.T) {
+	for _, test := range formatTests {
+		t.Run(test.name, func(t *testing.T) {
+			formatted, _ := Format("test.lox", test.source)
+			wantOut, wantErr := runBoth(t, test.source)
+			gotOut, gotErr := runBoth(t, formatted)
+			if gotOut != wantOut || gotErr != wantErr {
+				t.Errorf("formatted program printed:\n%s%s\noriginal printed:\n%s%s", gotOut, gotErr, wantOut, wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	got, diagnostics := Format("test.lox", "print ;")
+	if got != "" || len(diagnostics) != 1 {
+		t.Fatalf("Format = %q, %v; want a single diagnostic", got, diagnostics)
+	}
+	if want := "Expect expression."; diagnostics[0].Message != want {
+		t.Errorf("message = %q, want %q", diagnostics[0].Message, want)
+	}
+}
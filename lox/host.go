@@ -0,0 +1,58 @@
+package lox
+
+import "fmt"
+
+// GetGlobal returns the value of a global variable defined by the host or
+// by a script that has already run.
+func (in *Interpreter) GetGlobal(name string) (any, bool) {
+	return in.globals.Lookup(name)
+}
+
+// Call calls the global function or class called name. The arguments are
+// converted with ToLox, and the result is returned as a Lox value; use
+// FromLox to convert it to a particular Go type. Runtime errors in the
+// callee are returned as *Error with a traceback.
+func (in *Interpreter) Call(name string, args ...any) (any, error) {
+	callee, ok := in.GetGlobal(name)
+	if !ok {
+		return nil, fmt.Errorf("Undefined variable '%s'", name)
+	}
+	return in.CallValue(callee, args...)
+}
+
+// CallMethod calls the method called name on object, which is usually an
+// instance returned by an earlier call.
+func (in *Interpreter) CallMethod(object any, name string, args ...any) (any, error) {
+	accessor, ok := object.(PropertyAccessor)
+	if !ok {
+		return nil, fmt.Errorf("Only instances have properties")
+	}
+	method, err := accessor.GetProperty(name)
+	if err != nil {
+		return nil, err
+	}
+	return in.CallValue(method, args...)
+}
+
+// CallValue calls a Lox function, class or bound method.
+func (in *Interpreter) CallValue(callee any, args ...any) (any, error) {
+	f, ok := callee.(Callable)
+	if !ok {
+		return nil, fmt.Errorf("Can only call functions and classes")
+	}
+
+	arguments := make([]any, len(args))
+	for i, arg := range args {
+		v, err := ToLox(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		arguments[i] = v
+	}
+
+	if f.Arity() != Variadic && len(arguments) != f.Arity() {
+		return nil, fmt.Errorf("Expected %d arguments but got %d", f.Arity(), len(arguments))
+	}
+
+	return in.call(f, arguments, nil)
+}
@@ -0,0 +1,103 @@
+package lox
+
+import (
+	"io"
+	"testing"
+)
+
+const hostScript = `fun add(a, b) { return a + b; }
+fun fail(x) { return helper(x); }
+fun helper(x) { return x + nil; }
+class Greeter {
+  init(name) { this.name = name; }
+  greet(greeting) { return greeting + ", " + this.name; }
+}
+var notAFunction = 1;
+`
+
+func newHostInterpreter(t *testing.T, backend Backend) *Interpreter {
+	t.Helper()
+	in := NewInterpreter(WithBackend(backend), WithStderr(io.Discard))
+	in.run("host.lox", hostScript)
+	if in.ExitCode() != 0 {
+		t.Fatalf("script failed with status %d", in.ExitCode())
+	}
+	return in
+}
+
+func TestCall(t *testing.T) {
+	for _, b := range backends {
+		in := newHostInterpreter(t, b.backend)
+		result, err := in.Call("add", 2, 3)
+		if err != nil || result != 5.0 {
+			t.Errorf("%s: add(2, 3) = %v, %v; want 5", b.name, result, err)
+		}
+		result, err = in.Call("add", "a", "b")
+		if err != nil || result != "ab" {
+			t.Errorf("%s: add(\"a\", \"b\") = %v, %v; want ab", b.name, result, err)
+		}
+	}
+}
+
+func TestCallMethod(t *testing.T) {
+	for _, b := range backends {
+		in := newHostInterpreter(t, b.backend)
+		greeter, err := in.Call("Greeter", "Lox")
+		if err != nil {
+			t.Fatalf("%s: Greeter(): %v", b.name, err)
+		}
+		result, err := in.CallMethod(greeter, "greet", "Hello")
+		if err != nil || result != "Hello, Lox" {
+			t.Errorf("%s: greet() = %v, %v; want Hello, Lox", b.name, result, err)
+		}
+		if _, err := in.CallMethod(greeter, "missing"); err == nil {
+			t.Errorf("%s: calling a missing method succeeded", b.name)
+		}
+		if _, err := in.CallMethod(1.0, "greet"); err == nil {
+			t.Errorf("%s: calling a method on a number succeeded", b.name)
+		}
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []any
+		want string
+	}{
+		{"missing", nil, "Undefined variable 'missing'"},
+		{"notAFunction", nil, "Can only call functions and classes"},
+		{"add", []any{1}, "Expected 2 arguments but got 1"},
+	}
+	for _, b := range backends {
+		in := newHostInterpreter(t, b.backend)
+		for _, test := range tests {
+			_, err := in.Call(test.name, test.args...)
+			if err == nil {
+				t.Errorf("%s: %s%v succeeded", b.name, test.name, test.args)
+			} else if err.Error() != test.want {
+				t.Errorf("%s: %s%v: got %q, want %q", b.name, test.name, test.args, err, test.want)
+			}
+		}
+	}
+}
+
+func TestCallRuntimeErrorHasTrace(t *testing.T) {
+	for _, b := range backends {
+		in := newHostInterpreter(t, b.backend)
+		_, err := in.Call("fail", 1)
+		runtimeError, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("%s: got %v, want a runtime error", b.name, err)
+		}
+		if len(runtimeError.Trace) != 2 || runtimeError.Trace[0].Function != "helper" ||
+			runtimeError.Trace[0].Span.Line != 3 || runtimeError.Trace[1].Function != "fail" {
+			t.Errorf("%s: trace = %+v, want helper on line 3 called from fail", b.name, runtimeError.Trace)
+		}
+
+		// The interpreter is still usable afterwards.
+		if result, err := in.Call("add", 1, 1); err != nil || result != 2.0 {
+			t.Errorf("%s: add after an error = %v, %v", b.name, result, err)
+		}
+	}
+}
@@ -1,7 +1,5 @@
 package lox
 
-import "golox/lox/tok"
-
 type Instance struct {
 	class  *Class
 	fields map[string]any
@@ -18,23 +16,21 @@ func (i *Instance) String() string {
 	return i.class.name + " instance"
 }
 
-func (i *Instance) Get(name *tok.Token) (any, error) {
-	value, ok := i.fields[name.Lexeme]
+func (i *Instance) GetProperty(name string) (any, error) {
+	value, ok := i.fields[name]
 	if ok {
 		return value, nil
 	}
 
-	method := i.class.FindMethod(name.Lexeme)
+	method := i.class.FindMethod(name)
 	if method != nil {
 		return method.Bind(i), nil
 	}
 
-	return nil, &Error{
-		Token:   name,
-		Message: "Undefined property '" + name.Lexeme + "'",
-	}
+	return nil, undefinedProperty(name)
 }
 
-func (i *Instance) Set(name *tok.Token, value any) {
-	i.fields[name.Lexeme] = value
+func (i *Instance) SetProperty(name string, value any) error {
+	i.fields[name] = value
+	return nil
 }
@@ -0,0 +1,149 @@
+package lox
+
+import (
+	"bufio"
+	"golox/lox/expr"
+	"io"
+	"os"
+)
+
+type Backend int
+
+const (
+	// BackendTreeWalker executes the syntax tree directly.
+	BackendTreeWalker Backend = iota
+	// BackendVM compiles the syntax tree to bytecode and runs it on a
+	// stack-based virtual machine.
+	BackendVM
+)
+
+// localRef locates a local variable: the number of scopes between its use
+// and its declaration, and its slot within the declaring scope.
+type localRef struct {
+	depth int
+	slot  int
+}
+
+type Interpreter struct {
+	backend   Backend
+	vm        *vm
+	builtins  *Globals
+	globals   *Globals
+	main      *Module
+	module    *Module
+	modules   map[string]*Module
+	importing []*Module
+	env       *Environment
+	locals    map[expr.Expr]localRef
+	sources   map[string]string
+	frames    []callFrame
+	// depth counts the statements and expressions being run by the tree
+	// walker, which each take some of the Go stack.
+	depth             int
+	stdin             *bufio.Reader
+	stdout            io.Writer
+	stderr            io.Writer
+	fs                FileSystem
+	diagnosticHandler func(d *Diagnostic)
+	statementHook     StatementHook
+	profiler          *Profiler
+	hadError          bool
+	hadRuntimeError   bool
+}
+
+type Option func(in *Interpreter)
+
+// WithStdin sets the reader used by readLine and by the prompt.
+func WithStdin(r io.Reader) Option {
+	return func(in *Interpreter) {
+		in.stdin = bufio.NewReader(r)
+	}
+}
+
+// WithStdout sets the writer that receives the output of print statements.
+func WithStdout(w io.Writer) Option {
+	return func(in *Interpreter) {
+		in.stdout = w
+	}
+}
+
+// WithStderr sets the writer that receives scan, parse, resolve and runtime
+// error messages.
+func WithStderr(w io.Writer) Option {
+	return func(in *Interpreter) {
+		in.stderr = w
+	}
+}
+
+// WithDiagnosticHandler routes diagnostics to h instead of writing them to
+// the error writer.
+func WithDiagnosticHandler(h func(d *Diagnostic)) Option {
+	return func(in *Interpreter) {
+		in.diagnosticHandler = h
+	}
+}
+
+// WithFileSystem sets the file system used by the file functions and to
+// load imported modules, which is OSFileSystem by default. A nil FileSystem
+// disables file access, and with it imports.
+func WithFileSystem(fs FileSystem) Option {
+	return func(in *Interpreter) {
+		in.fs = fs
+	}
+}
+
+// WithBackend selects how programs are executed. The default is
+// BackendTreeWalker.
+func WithBackend(b Backend) Option {
+	return func(in *Interpreter) {
+		in.backend = b
+	}
+}
+
+// WithStatementHook sets a function to call before each statement runs.
+// Only the tree-walking interpreter calls it.
+func WithStatementHook(hook StatementHook) Option {
+	return func(in *Interpreter) {
+		in.statementHook = hook
+	}
+}
+
+// WithProfiler records a profile of the programs run in p. Only the
+// tree-walking interpreter is profiled.
+func WithProfiler(p *Profiler) Option {
+	return func(in *Interpreter) {
+		in.profiler = p
+		p.sources = in.sources
+	}
+}
+
+func NewInterpreter(options ...Option) *Interpreter {
+	builtins := NewGlobals(nil)
+	globals := NewGlobals(builtins)
+	main := newModule("", "", globals)
+	in := &Interpreter{
+		builtins: builtins,
+		globals:  globals,
+		main:     main,
+		module:   main,
+		modules:  make(map[string]*Module),
+		locals:   make(map[expr.Expr]localRef),
+		sources:  make(map[string]string),
+		stdin:    bufio.NewReader(os.Stdin),
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+		fs:       OSFileSystem{},
+	}
+	for _, option := range options {
+		option(in)
+	}
+	if in.backend == BackendVM {
+		in.vm = newVM(in)
+	}
+	defineBuiltins(in)
+	return in
+}
+
+func (in *Interpreter) resolve(e expr.Expr, depth int, slot int) {
+	in.locals[e] = localRef{depth: depth, slot: slot}
+}
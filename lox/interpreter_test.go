@@ -0,0 +1,116 @@
+package lox
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var backends = []struct {
+	name    string
+	backend Backend
+}{
+	{"tree", BackendTreeWalker},
+	{"vm", BackendVM},
+}
+
+// runSource runs source as a script called "test.lox" and returns what it
+// wrote to standard output and standard error.
+func runSource(t *testing.T, source string, options ...Option) (string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	options = append([]Option{
+		WithStdout(&stdout),
+		WithStderr(&stderr),
+		WithStdin(strings.NewReader("")),
+		WithFileSystem(nil),
+	}, options...)
+	in := NewInterpreter(options...)
+	in.run("test.lox", source)
+	return stdout.String(), stderr.String()
+}
+
+// runBoth runs source on both backends, checks that they agree, and
+// returns the output.
+func runBoth(t *testing.T, source string, options ...Option) (string, string) {
+	t.Helper()
+	var stdout, stderr [2]string
+	for i, b := range backends {
+		stdout[i], stderr[i] = runSource(t, source, append(options, WithBackend(b.backend))...)
+	}
+	if stdout[0] != stdout[1] || stderr[0] != stderr[1] {
+		t.Errorf("backends disagree on\n%s\ntree:\n%s%s\nvm:\n%s%s",
+			source, stdout[0], stderr[0], stdout[1], stderr[1])
+	}
+	return stdout[0], stderr[0]
+}
+
+// expectOutput runs source on both backends and checks that it prints
+// want with no errors.
+func expectOutput(t *testing.T, source string, want string) {
+	t.Helper()
+	stdout, stderr := runBoth(t, source)
+	if stderr != "" {
+		t.Errorf("unexpected errors from\n%s\n%s", source, stderr)
+	}
+	if stdout != want {
+		t.Errorf("output of\n%s\ngot:\n%s\nwant:\n%s", source, stdout, want)
+	}
+}
+
+// expectError runs source on both backends and checks that the first line
+// of the error it reports is want.
+func expectError(t *testing.T, source string, want string) {
+	t.Helper()
+	_, stderr := runBoth(t, source)
+	got, _, _ := strings.Cut(stderr, "\n")
+	if got != want {
+		t.Errorf("error from\n%s\ngot:  %q\nwant: %q\nfull output:\n%s", source, got, want, stderr)
+	}
+}
+
+func TestInterpretersAreIndependent(t *testing.T) {
+	var out1, out2 bytes.Buffer
+	in1 := NewInterpreter(WithStdout(&out1))
+	in2 := NewInterpreter(WithStdout(&out2))
+
+	in1.run("", "var x = 1;")
+	in2.run("", "var x = 2;")
+	in1.run("", "print x;")
+	in2.run("", "print x;")
+
+	if out1.String() != "1\n" || out2.String() != "2\n" {
+		t.Errorf("got %q and %q, want \"1\\n\" and \"2\\n\"", out1.String(), out2.String())
+	}
+}
+
+func TestGlobalsPersistBetweenRuns(t *testing.T) {
+	var out bytes.Buffer
+	in := NewInterpreter(WithStdout(&out))
+	in.run("", "fun double(n) { return n * 2; }")
+	in.run("", "var x = double(21);")
+	if x, ok := in.GetGlobal("x"); !ok || x != 42.0 {
+		t.Errorf("x = %v, %v; want 42, true", x, ok)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int
+	}{
+		{"print 1;", 0},
+		{"print ;", 65},
+		{"print nil + 1;", 70},
+	}
+	for _, test := range tests {
+		for _, b := range backends {
+			var discard bytes.Buffer
+			in := NewInterpreter(WithStdout(&discard), WithStderr(&discard), WithBackend(b.backend))
+			in.run("test.lox", test.source)
+			if got := in.ExitCode(); got != test.want {
+				t.Errorf("%s: ExitCode() after %q = %d, want %d", b.name, test.source, got, test.want)
+			}
+		}
+	}
+}
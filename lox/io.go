@@ -0,0 +1,186 @@
+package lox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// FileSystem gives scripts access to files. The host can restrict or
+// replace it with WithFileSystem.
+type FileSystem interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+}
+
+// File is an open file returned by a FileSystem.
+type File interface {
+	io.Reader
+	io.Writer
+	io.Closer
+}
+
+// OSFileSystem is the FileSystem backed by the operating system, which is
+// used by default.
+type OSFileSystem struct{}
+
+func (OSFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+var errNoFileSystem = errors.New("File access is disabled")
+
+func (in *Interpreter) openFile(name string, flag int) (File, error) {
+	if in.fs == nil {
+		return nil, errNoFileSystem
+	}
+	return in.fs.OpenFile(name, flag, 0o644)
+}
+
+// readLine reads a line from r without the line ending. It returns nil at
+// the end of the input.
+func readLine(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return nil, nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+func defineIO(in *Interpreter) {
+	in.DefineNative("readLine", 0, func(args []any) (any, error) {
+		return readLine(in.stdin)
+	})
+
+	in.DefineNative("readFile", 1, func(args []any) (any, error) {
+		path, err := stringArgument("readFile", args[0])
+		if err != nil {
+			return nil, err
+		}
+		f, err := in.openFile(path, os.O_RDONLY)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		bytes, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return string(bytes), nil
+	})
+
+	writer := func(name string, flag int) func(args []any) (any, error) {
+		return func(args []any) (any, error) {
+			path, err := stringArgument(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+			text, err := stringArgument(name, args[1])
+			if err != nil {
+				return nil, err
+			}
+			f, err := in.openFile(path, flag)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.WriteString(f, text); err != nil {
+				f.Close()
+				return nil, err
+			}
+			return nil, f.Close()
+		}
+	}
+	in.DefineNative("writeFile", 2, writer("writeFile", os.O_WRONLY|os.O_CREATE|os.O_TRUNC))
+	in.DefineNative("appendFile", 2, writer("appendFile", os.O_WRONLY|os.O_CREATE|os.O_APPEND))
+
+	in.DefineNative("open", 2, func(args []any) (any, error) {
+		path, err := stringArgument("open", args[0])
+		if err != nil {
+			return nil, err
+		}
+		mode, err := stringArgument("open", args[1])
+		if err != nil {
+			return nil, err
+		}
+		var flag int
+		switch mode {
+		case "r":
+			flag = os.O_RDONLY
+		case "w":
+			flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		case "a":
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		default:
+			return nil, fmt.Errorf("File mode must be \"r\", \"w\" or \"a\" but got %q", mode)
+		}
+		f, err := in.openFile(path, flag)
+		if err != nil {
+			return nil, err
+		}
+		return &FileHandle{path: path, file: f, reader: bufio.NewReader(f)}, nil
+	})
+}
+
+// FileHandle is a file opened by a script with open(path, mode).
+type FileHandle struct {
+	path   string
+	file   File
+	reader *bufio.Reader
+	closed bool
+}
+
+func (f *FileHandle) String() string {
+	return "<file " + f.path + ">"
+}
+
+func (f *FileHandle) GetProperty(name string) (any, error) {
+	var method *NativeFunction
+	switch name {
+	case "read":
+		method = NewNativeFunction(name, 0, func(args []any) (any, error) {
+			bytes, err := io.ReadAll(f.reader)
+			if err != nil {
+				return nil, err
+			}
+			return string(bytes), nil
+		})
+	case "readLine":
+		method = NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return readLine(f.reader)
+		})
+	case "write":
+		method = NewNativeFunction(name, 1, func(args []any) (any, error) {
+			text, err := stringArgument(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.WriteString(f.file, text)
+			return nil, err
+		})
+	case "close":
+		method = NewNativeFunction(name, 0, func(args []any) (any, error) {
+			f.closed = true
+			return nil, f.file.Close()
+		})
+	default:
+		return nil, undefinedProperty(name)
+	}
+
+	fn := method.fn
+	method.fn = func(args []any) (any, error) {
+		if f.closed {
+			return nil, errors.New("File is closed")
+		}
+		return fn(args)
+	}
+	return method, nil
+}
+
+func (f *FileHandle) SetProperty(name string, value any) error {
+	return errors.New("Can't set properties on a file")
+}
@@ -0,0 +1,81 @@
+package lox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadLine(t *testing.T) {
+	source := `var line = readLine();
+while (line != nil) {
+  print "[" + line + "]";
+  line = readLine();
+}`
+	for _, b := range backends {
+		stdout, stderr := runSource(t, source,
+			WithStdin(strings.NewReader("one\r\ntwo\n\nlast")), WithBackend(b.backend))
+		want := "[one]\n[two]\n[]\n[last]\n"
+		if stdout != want || stderr != "" {
+			t.Errorf("%s: got %q, %q; want %q", b.name, stdout, stderr, want)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	// Lox strings have no escapes, so the newlines are written literally.
+	source := "var path = \"" + dir + "/out.txt\";\n" +
+		"writeFile(path, \"one\n\");\n" +
+		"appendFile(path, \"two\n\");\n" +
+		`print readFile(path);
+var f = open(path, "r");
+print f.readLine();
+print f.read();
+print f.readLine();
+f.close();
+var w = open(path, "a");
+w.write("three");
+w.close();
+print readFile(path).split("` + "\n" + `");
+print w;`
+	for _, b := range backends {
+		stdout, stderr := runSource(t, source, WithFileSystem(OSFileSystem{}), WithBackend(b.backend))
+		want := "one\ntwo\n\none\ntwo\n\nnil\n" + `["one", "two", "three"]` + "\n<file " + dir + "/out.txt>\n"
+		if stdout != want || stderr != "" {
+			t.Errorf("%s: got %q, %q; want %q", b.name, stdout, stderr, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.txt")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`readFile("` + dir + `/missing.txt");`, "open " + dir + "/missing.txt: no such file or directory"},
+		{`open("` + dir + `/x.txt", "rw");`, `File mode must be "r", "w" or "a" but got "rw"`},
+		{`var f = open("` + dir + `/x.txt", "w"); f.close(); f.write("x");`, "File is closed"},
+		{`var f = open("` + dir + `/x.txt", "w"); f.mode = "r";`, "Can't set properties on a file"},
+		{"readFile(1);", "readFile expects a string but got number"},
+	}
+	for _, test := range tests {
+		for _, b := range backends {
+			_, stderr := runSource(t, test.source, WithFileSystem(OSFileSystem{}), WithBackend(b.backend))
+			if got, _, _ := strings.Cut(stderr, "\n"); got != test.want {
+				t.Errorf("%s: error from\n%s\ngot:  %q\nwant: %q", b.name, test.source, got, test.want)
+			}
+		}
+	}
+}
+
+func TestFileAccessCanBeDisabled(t *testing.T) {
+	expectError(t, `readFile("x.txt");`, "File access is disabled")
+	expectError(t, `writeFile("x.txt", "x");`, "File access is disabled")
+	expectError(t, `open("x.txt", "r");`, "File access is disabled")
+}
@@ -0,0 +1,99 @@
+package lox
+
+import (
+	"sort"
+	"strings"
+)
+
+// Lint checks source for code that is legal but probably a mistake, such
+// as unused variables and unreachable code. It returns warnings sorted by
+// position, or the errors if source doesn't compile.
+//
+// A comment of the form "// lint:ignore code ..." suppresses warnings with
+// the given codes on the same line, and if the comment is on a line of its
+// own, on the line after it. With no codes it suppresses all warnings
+// there.
+func Lint(file string, source string) []*Diagnostic {
+	statements, diagnostics := parse(file, source)
+	if len(diagnostics) > 0 {
+		return diagnostics
+	}
+
+	resolver := NewResolver(nil)
+	resolver.lint = true
+	resolver.ResolveStatements(statements)
+	resolver.checkGlobals()
+
+	ignored := ignoredWarnings(source)
+	var result []*Diagnostic
+	for _, d := range resolver.Diagnostics() {
+		d.Span.File = file
+		if d.Severity == SeverityWarning && ignored.match(d) {
+			continue
+		}
+		result = append(result, d)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Span.Offset < result[j].Span.Offset
+	})
+	return result
+}
+
+// ignoreSet maps a line to the codes ignored on it. A nil slice means all
+// codes are ignored.
+type ignoreSet map[int][]Code
+
+func ignoredWarnings(source string) ignoreSet {
+	ignored := make(ignoreSet)
+	scanner := NewScanner(source)
+	scanner.ScanTokens()
+	for _, c := range scanner.Comments() {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Lexeme, "//"))
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) == 0 || fields[0] != "lint:ignore" {
+			continue
+		}
+		var codes []Code
+		for _, field := range fields[1:] {
+			codes = append(codes, Code(field))
+		}
+		ignored.add(c.Line, codes)
+		if aloneOnLine(source, c.Offset) {
+			ignored.add(c.Line+1, codes)
+		}
+	}
+	return ignored
+}
+
+// aloneOnLine reports whether only spaces come before offset on its line.
+func aloneOnLine(source string, offset int) bool {
+	start := strings.LastIndexByte(source[:offset], '\n') + 1
+	return strings.TrimSpace(source[start:offset]) == ""
+}
+
+func (s ignoreSet) add(line int, codes []Code) {
+	existing, ok := s[line]
+	if ok && (existing == nil || codes == nil) {
+		s[line] = nil
+	} else {
+		s[line] = append(existing, codes...)
+	}
+}
+
+func (s ignoreSet) match(d *Diagnostic) bool {
+	codes, ok := s[d.Span.Line]
+	if !ok {
+		return false
+	}
+	if codes == nil {
+		return true
+	}
+	for _, code := range codes {
+		if code == d.Code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package lox
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// lint returns the code and line of each diagnostic for source.
+func lint(source string) []string {
+	var got []string
+	for _, d := range Lint("test.lox", source) {
+		got = append(got, fmt.Sprintf("%s@%d", d.Code, d.Span.Line))
+	}
+	return got
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "clean",
+			source: "fun f(a) { return a; }\nprint f(1);",
+			want:   nil,
+		},
+		{
+			name:   "unused",
+			source: "fun f(a) {\n  var x = 1;\n  return 1;\n}",
+			want:   []string{"unused-parameter@1", "unused-variable@2"},
+		},
+		{
+			name:   "unreachable",
+			source: "fun f() {\n  return 1;\n  print 2;\n}",
+			want:   []string{"unreachable-code@3"},
+		},
+		{
+			name:   "shadowed",
+			source: "{\n  var a = 1;\n  {\n    var a = 2;\n    print a;\n  }\n  print a;\n}",
+			want:   []string{"shadowed-variable@4"},
+		},
+		{
+			name:   "undeclared global",
+			source: "missing = 1;",
+			want:   []string{"undeclared-global@1"},
+		},
+		{
+			name:   "constant condition",
+			source: "if (true) print 1;",
+			want:   []string{"constant-condition@1"},
+		},
+		{
+			name:   "errors are returned instead",
+			source: "var x = ;",
+			want:   []string{"expected-expression@1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := lint(test.source); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLintIgnore(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "comment on its own line covers the next line",
+			source: "fun f() {\n  // lint:ignore unused-variable\n  var x = 1;\n  var y = 2;\n}",
+			want:   []string{"unused-variable@4"},
+		},
+		{
+			name:   "trailing comment covers only its own line",
+			source: "fun f() {\n  var x = 1; // lint:ignore unused-variable\n  var y = 2;\n}",
+			want:   []string{"unused-variable@3"},
+		},
+		{
+			name:   "other codes are still reported",
+			source: "fun f(a) { // lint:ignore unused-variable\n  var x = 1;\n}",
+			want:   []string{"unused-parameter@1", "unused-variable@2"},
+		},
+		{
+			name:   "several codes",
+			source: "// lint:ignore unused-parameter, unused-variable\nfun f(a) { var x = 1; }",
+			want:   nil,
+		},
+		{
+			name:   "no codes ignores everything",
+			source: "fun f(a) {\n  return 1;\n  print 2; // lint:ignore\n}",
+			want:   []string{"unused-parameter@1"},
+		},
+		{
+			name:   "errors can't be ignored",
+			source: "// lint:ignore\nreturn 1;",
+			want:   []string{"top-level-return@2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := lint(test.source); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
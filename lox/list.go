@@ -0,0 +1,125 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+)
+
+// List is a growable array of Lox values, created with a list literal
+// like "[1, 2, 3]".
+type List struct {
+	elements []any
+}
+
+func NewList(elements []any) *List {
+	return &List{elements: elements}
+}
+
+// Elements returns the elements of the list. The slice is shared with the
+// list, so changes to one are visible in the other.
+func (l *List) Elements() []any {
+	return l.elements
+}
+
+func (l *List) String() string {
+	return repr(l, make(map[any]bool))
+}
+
+func (l *List) GetProperty(name string) (any, error) {
+	switch name {
+	case "push":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			l.elements = append(l.elements, args[0])
+			return nil, nil
+		}), nil
+	case "pop":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			if len(l.elements) == 0 {
+				return nil, errors.New("Can't pop from an empty list")
+			}
+			value := l.elements[len(l.elements)-1]
+			l.elements = l.elements[:len(l.elements)-1]
+			return value, nil
+		}), nil
+	case "len":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return float64(len(l.elements)), nil
+		}), nil
+	case "slice":
+		return NewNativeFunction(name, 2, func(args []any) (any, error) {
+			start, err := checkIndex("List", args[0], len(l.elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			end, err := checkIndex("List", args[1], len(l.elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			if end < start {
+				return nil, errors.New("Slice end is before start")
+			}
+			elements := make([]any, end-start)
+			copy(elements, l.elements[start:end])
+			return NewList(elements), nil
+		}), nil
+	case "insert":
+		return NewNativeFunction(name, 2, func(args []any) (any, error) {
+			i, err := checkIndex("List", args[0], len(l.elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			l.elements = append(l.elements, nil)
+			copy(l.elements[i+1:], l.elements[i:])
+			l.elements[i] = args[1]
+			return nil, nil
+		}), nil
+	case "remove":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			i, err := checkIndex("List", args[0], len(l.elements))
+			if err != nil {
+				return nil, err
+			}
+			value := l.elements[i]
+			l.elements = append(l.elements[:i], l.elements[i+1:]...)
+			return value, nil
+		}), nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+func (l *List) SetProperty(name string, value any) error {
+	return errors.New("Can't set properties on a list")
+}
+
+func (l *List) GetIndex(index any) (any, error) {
+	i, err := checkIndex("List", index, len(l.elements))
+	if err != nil {
+		return nil, err
+	}
+	return l.elements[i], nil
+}
+
+func (l *List) SetIndex(index any, value any) error {
+	i, err := checkIndex("List", index, len(l.elements))
+	if err != nil {
+		return err
+	}
+	l.elements[i] = value
+	return nil
+}
+
+// checkIndex checks that index is an integer in the range [0, length).
+// kind names the type being indexed in error messages.
+func checkIndex(kind string, index any, length int) (int, error) {
+	n, ok := index.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s index must be a number but got %s", kind, typeName(index))
+	}
+	if n != float64(int(n)) {
+		return 0, fmt.Errorf("%s index must be an integer but got %s", kind, formatNumber(n))
+	}
+	if n < 0 || int(n) >= length {
+		return 0, fmt.Errorf("%s index %s out of bounds", kind, formatNumber(n))
+	}
+	return int(n), nil
+}
@@ -0,0 +1,50 @@
+package lox
+
+import "testing"
+
+func TestLists(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print [1, "two", nil, [3]];`, `[1, "two", nil, [3]]` + "\n"},
+		{"print [];", "[]\n"},
+		{"print [1, 2,];", "[1, 2]\n"},
+		{"var xs = [1, 2, 3]; print xs[0] + xs[2];", "4\n"},
+		{"var xs = [1, 2]; xs[1] = 5; print xs;", "[1, 5]\n"},
+		{"var xs = [1]; print xs[0] = 9;", "9\n"},
+		{"var m = [[1, 2], [3, 4]]; m[1][0] = 9; print m;", "[[1, 2], [9, 4]]\n"},
+		{"var xs = []; xs.push(1); xs.push(2); print xs.len();", "2\n"},
+		{"var xs = [1, 2]; print xs.pop(); print xs;", "2\n[1]\n"},
+		{`var xs = [1, 3]; xs.insert(1, 2); xs.insert(3, 4); print xs;`, "[1, 2, 3, 4]\n"},
+		{"var xs = [1, 2, 3]; print xs.remove(1); print xs;", "2\n[1, 3]\n"},
+		{"var xs = [1, 2, 3, 4]; print xs.slice(1, 3); print xs.slice(4, 4);", "[2, 3]\n[]\n"},
+		{"var xs = [1]; var push = xs.push; push(2); print xs;", "[1, 2]\n"},
+		{"var xs = [1]; print xs == xs; print [1] == [1];", "true\nfalse\n"},
+		{"var xs = [1]; xs.push(xs); print xs;", "[1, [...]]\n"},
+	}
+	for _, test := range tests {
+		expectOutput(t, test.source, test.want)
+	}
+}
+
+func TestListErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print [1][1];", "List index 1 out of bounds"},
+		{"print [1][-1];", "List index -1 out of bounds"},
+		{"print [1][0.5];", "List index must be an integer but got 0.5"},
+		{`print [1]["0"];`, "List index must be a number but got string"},
+		{"[].pop();", "Can't pop from an empty list"},
+		{"[1, 2].slice(2, 1);", "Slice end is before start"},
+		{"[].remove(0);", "List index 0 out of bounds"},
+		{"[].x = 1;", "Can't set properties on a list"},
+		{"[].missing();", "Undefined property 'missing'"},
+		{"var n = 1; print n[0];", "Only lists and maps can be indexed"},
+	}
+	for _, test := range tests {
+		expectError(t, test.source, test.want)
+	}
+}
@@ -0,0 +1,58 @@
+package lox
+
+import "testing"
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var i = 0; while (true) { if (i == 3) break; print i; i = i + 1; }", "0\n1\n2\n"},
+		{"for (var i = 0; i < 5; i = i + 1) { if (i % 2 == 0) continue; print i; }", "1\n3\n"},
+		{"for (var i = 0; i < 5; i = i + 1) { if (i == 2) break; print i; }", "0\n1\n"},
+		// continue in a for loop still runs the increment.
+		{"var n = 0; for (var i = 0; i < 3; i = i + 1) { n = n + 1; continue; } print n;", "3\n"},
+		{"var i = 0; while (i < 4) { i = i + 1; if (i == 2) continue; print i; }", "1\n3\n4\n"},
+		// break and continue apply to the innermost loop.
+		{`for (var i = 0; i < 2; i = i + 1) {
+  for (var j = 0; j < 3; j = j + 1) {
+    if (j == 1) continue;
+    if (j == 2) break;
+    print i * 10 + j;
+  }
+}`, "0\n10\n"},
+		// Leaving a block with break discards its locals.
+		{`var fs = [];
+for (var i = 0; i < 3; i = i + 1) {
+  var j = i;
+  fun f() { return j; }
+  fs.push(f);
+  if (i == 1) break;
+}
+print fs[0]() + fs[1]();`, "1\n"},
+		{"var i = 0; while (true) { { var x = i; i = i + 1; if (x > 1) break; } } print i;", "3\n"},
+		// break goes through finally.
+		{`while (true) {
+  try { break; } finally { print "finally"; }
+}
+print "after";`, "finally\nafter\n"},
+	}
+	for _, test := range tests {
+		expectOutput(t, test.source, test.want)
+	}
+}
+
+func TestBreakAndContinueOutsideLoops(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"break;", "[line 1] Error at 'break': Can't use 'break' outside a loop"},
+		{"continue;", "[line 1] Error at 'continue': Can't use 'continue' outside a loop"},
+		{"while (true) { fun f() { break; } }", "[line 1] Error at 'break': Can't use 'break' outside a loop"},
+		{"while (true) break", "[line 1] Error at end: Expect ';' after 'break'"},
+	}
+	for _, test := range tests {
+		expectError(t, test.source, test.want)
+	}
+}
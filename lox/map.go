@@ -0,0 +1,129 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Map is a collection of key/value pairs, created with a map literal like
+// {"a": 1, "b": 2}. Keys may be strings, numbers, booleans or nil. Keys are
+// kept in insertion order.
+type Map struct {
+	index  map[any]int
+	keys   []any
+	values []any
+}
+
+func NewMap() *Map {
+	return &Map{index: make(map[any]int)}
+}
+
+// Keys returns the keys of the map in insertion order.
+func (m *Map) Keys() []any {
+	return m.keys
+}
+
+// Lookup returns the value for key, if there is one.
+func (m *Map) Lookup(key any) (any, bool) {
+	i, ok := m.index[key]
+	if !ok {
+		return nil, false
+	}
+	return m.values[i], true
+}
+
+// Put sets the value for key, returning an error if key isn't hashable.
+func (m *Map) Put(key any, value any) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if i, ok := m.index[key]; ok {
+		m.values[i] = value
+		return nil
+	}
+	m.index[key] = len(m.keys)
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+	return nil
+}
+
+func (m *Map) remove(key any) (any, bool) {
+	i, ok := m.index[key]
+	if !ok {
+		return nil, false
+	}
+	value := m.values[i]
+	delete(m.index, key)
+	m.keys = append(m.keys[:i], m.keys[i+1:]...)
+	m.values = append(m.values[:i], m.values[i+1:]...)
+	for j := i; j < len(m.keys); j++ {
+		m.index[m.keys[j]] = j
+	}
+	return value, true
+}
+
+func (m *Map) String() string {
+	return repr(m, make(map[any]bool))
+}
+
+func (m *Map) GetIndex(key any) (any, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	value, ok := m.Lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("Undefined key %s", Repr(key))
+	}
+	return value, nil
+}
+
+func (m *Map) SetIndex(key any, value any) error {
+	return m.Put(key, value)
+}
+
+func (m *Map) GetProperty(name string) (any, error) {
+	switch name {
+	case "len":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return float64(len(m.keys)), nil
+		}), nil
+	case "keys":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return NewList(append([]any(nil), m.keys...)), nil
+		}), nil
+	case "values":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return NewList(append([]any(nil), m.values...)), nil
+		}), nil
+	case "has":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			if err := checkKey(args[0]); err != nil {
+				return nil, err
+			}
+			_, ok := m.index[args[0]]
+			return ok, nil
+		}), nil
+	case "remove":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			if err := checkKey(args[0]); err != nil {
+				return nil, err
+			}
+			value, _ := m.remove(args[0])
+			return value, nil
+		}), nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+func (m *Map) SetProperty(name string, value any) error {
+	return errors.New("Can't set properties on a map")
+}
+
+// checkKey reports an error if key can't be used as a map key.
+func checkKey(key any) error {
+	switch key.(type) {
+	case nil, bool, float64, string:
+		return nil
+	}
+	return fmt.Errorf("Map keys must be strings, numbers, booleans or nil but got %s", typeName(key))
+}
@@ -0,0 +1,43 @@
+package lox
+
+import "testing"
+
+func TestMaps(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print {"a": 1, 2: "two", true: nil, nil: [3]};`, `{"a": 1, 2: "two", true: nil, nil: [3]}` + "\n"},
+		{"print {};", "{}\n"},
+		{`print {"a": 1,};`, `{"a": 1}` + "\n"},
+		{`var m = {"a": 1}; print m["a"];`, "1\n"},
+		{`var m = {}; m["b"] = 2; m["a"] = 1; m["b"] = 3; print m;`, `{"b": 3, "a": 1}` + "\n"},
+		{`var m = {"a": 1, "b": 2}; print m.len(); print m.keys(); print m.values();`, "2\n[\"a\", \"b\"]\n[1, 2]\n"},
+		{`var m = {"a": 1}; print m.has("a"); print m.has("b");`, "true\nfalse\n"},
+		{`var m = {"a": 1, "b": 2, "c": 3}; print m.remove("b"); print m.remove("x"); print m; print m["c"];`, "2\nnil\n{\"a\": 1, \"c\": 3}\n3\n"},
+		{`var m = {1: "one"}; print m[1.0];`, "one\n"},
+		{`var m = {}; m["self"] = m; print m;`, `{"self": {...}}` + "\n"},
+		{`var m = {"f": 1}; var keys = m.keys(); keys.push("g"); print m.len();`, "1\n"},
+	}
+	for _, test := range tests {
+		expectOutput(t, test.source, test.want)
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print {"a": 1}["b"];`, `Undefined key "b"`},
+		{`print {}[[1]];`, "Map keys must be strings, numbers, booleans or nil but got list"},
+		{`var m = {}; m[{}] = 1;`, "Map keys must be strings, numbers, booleans or nil but got map"},
+		{`print {[1]: 2};`, "Map keys must be strings, numbers, booleans or nil but got list"},
+		{`var m = {}; m.has([]);`, "Map keys must be strings, numbers, booleans or nil but got list"},
+		{`var m = {}; m.x = 1;`, "Can't set properties on a map"},
+		{`var m = {}; m.missing;`, "Undefined property 'missing'"},
+	}
+	for _, test := range tests {
+		expectError(t, test.source, test.want)
+	}
+}
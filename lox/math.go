@@ -0,0 +1,73 @@
+package lox
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// newMathModule returns the builtin math module. Its random number
+// generator is seeded from the clock unless a script calls math.seed.
+func newMathModule() *Module {
+	m := newModule("math", "", NewGlobals(nil))
+	define := func(name string, value any) {
+		m.globals.Define(name, value)
+		m.exports[name] = true
+	}
+
+	functions := map[string]any{
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"round": math.Round,
+		"sqrt":  math.Sqrt,
+		"pow":   math.Pow,
+		"abs":   math.Abs,
+		"sin":   math.Sin,
+		"cos":   math.Cos,
+		"tan":   math.Tan,
+		"log":   math.Log,
+		"exp":   math.Exp,
+		"min":   minimum,
+		"max":   maximum,
+	}
+	for name, fn := range functions {
+		f, err := WrapFunc(name, fn)
+		if err != nil {
+			panic(err)
+		}
+		define(name, f)
+	}
+
+	define("pi", math.Pi)
+	define("e", math.E)
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	define("random", NewNativeFunction("random", 0, func(args []any) (any, error) {
+		return rng.Float64(), nil
+	}))
+	define("seed", NewNativeFunction("seed", 1, func(args []any) (any, error) {
+		n, ok := args[0].(float64)
+		if !ok || n != math.Trunc(n) {
+			return nil, errors.New("seed expects an integer")
+		}
+		rng.Seed(int64(n))
+		return nil, nil
+	}))
+
+	return m
+}
+
+func minimum(x float64, xs ...float64) float64 {
+	for _, y := range xs {
+		x = math.Min(x, y)
+	}
+	return x
+}
+
+func maximum(x float64, xs ...float64) float64 {
+	for _, y := range xs {
+		x = math.Max(x, y)
+	}
+	return x
+}
@@ -0,0 +1,47 @@
+package lox
+
+import "testing"
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print math.floor(2.7); print math.ceil(2.1); print math.round(2.5); print math.round(-2.5);", "2\n3\n3\n-3\n"},
+		{"print math.sqrt(16); print math.pow(2, 10); print math.abs(-3);", "4\n1024\n3\n"},
+		{"print math.min(3, 1, 2); print math.max(3, 1, 2); print math.min(5);", "1\n3\n5\n"},
+		{"print math.sin(0); print math.cos(0); print math.tan(0);", "0\n1\n0\n"},
+		{"print math.log(math.e); print math.exp(0);", "1\n1\n"},
+		{"print math.pi;", "3.141592653589793\n"},
+		{"print math.sqrt(-1); print math.log(0);", "NaN\n-Infinity\n"},
+		{"print math;", "<module math>\n"},
+		{"var floor = math.floor; print floor(1.5);", "1\n"},
+		{`math.seed(42);
+var a = math.random();
+var b = math.random();
+math.seed(42);
+print a == math.random() and b == math.random();
+print a >= 0 and a < 1;`, "true\ntrue\n"},
+	}
+	for _, test := range tests {
+		expectOutput(t, test.source, test.want)
+	}
+}
+
+func TestMathErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`math.sqrt("4");`, "sqrt: argument 1: expected a number but got string"},
+		{"math.pow(2);", "Expected 2 arguments but got 1"},
+		{"math.min();", "Expected at least 1 arguments but got 0"},
+		{"math.max(1, nil);", "max: argument 2: expected float64 but got nil"},
+		{"math.seed(1.5);", "seed expects an integer"},
+		{"math.tau;", "Module 'math' has no export 'tau'"},
+		{"math.pi = 3;", "Can't assign to a module's exports"},
+	}
+	for _, test := range tests {
+		expectError(t, test.source, test.want)
+	}
+}
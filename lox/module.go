@@ -0,0 +1,191 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"golox/lox/stmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Module is a Lox source file loaded by an import statement. Each module
+// runs once, in its own globals, and the value bound by the import gives
+// access to the names it exports.
+type Module struct {
+	name    string
+	path    string
+	globals *Globals
+	exports map[string]bool
+}
+
+func newModule(name string, path string, globals *Globals) *Module {
+	return &Module{
+		name:    name,
+		path:    path,
+		globals: globals,
+		exports: make(map[string]bool),
+	}
+}
+
+func (m *Module) String() string {
+	return "<module " + m.name + ">"
+}
+
+func (m *Module) GetProperty(name string) (any, error) {
+	if !m.exports[name] {
+		return nil, fmt.Errorf("Module '%s' has no export '%s'", m.name, name)
+	}
+	return m.globals.values[name], nil
+}
+
+func (m *Module) SetProperty(name string, value any) error {
+	return errors.New("Can't assign to a module's exports")
+}
+
+// importModule loads the module named by an import statement, running it if
+// it hasn't been run already. The path is relative to the directory of the
+// importing module.
+func (in *Interpreter) importModule(s *stmt.Import) (*Module, error) {
+	name := s.Path.Literal.(string)
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(in.module.path), path)
+	}
+	path = filepath.Clean(path)
+
+	if m, ok := in.modules[path]; ok {
+		for i, loading := range in.importing {
+			if loading == m {
+				return nil, &Error{
+					Token:   s.Path,
+					Message: "Circular import: " + importChain(in.importing[i:], m),
+				}
+			}
+		}
+		return m, nil
+	}
+
+	source, err := in.readModule(path)
+	if err != nil {
+		var pathError *os.PathError
+		if errors.As(err, &pathError) {
+			err = pathError.Err
+		}
+		return nil, &Error{
+			Token:   s.Path,
+			Message: fmt.Sprintf("Can't import '%s': %s", name, err),
+		}
+	}
+
+	in.sources[path] = source
+	statements, diagnostics := in.Check(path, source)
+	hadError := false
+	for _, d := range diagnostics {
+		in.report(d)
+		hadError = hadError || d.Severity == SeverityError
+	}
+	if hadError {
+		return nil, &Error{
+			Token:   s.Path,
+			Message: fmt.Sprintf("Can't import '%s' because it has errors", name),
+		}
+	}
+
+	var p *proto
+	if in.backend == BackendVM {
+		var ok bool
+		if p, ok = in.compile(statements); !ok {
+			return nil, &Error{
+				Token:   s.Path,
+				Message: fmt.Sprintf("Can't import '%s' because it has errors", name),
+			}
+		}
+	}
+
+	m := newModule(name, path, NewGlobals(in.builtins))
+	for _, st := range statements {
+		if export, ok := st.(*stmt.Export); ok {
+			m.exports[declarationName(export.Declaration)] = true
+		}
+	}
+
+	in.modules[path] = m
+	if err := in.runModule(m, s, statements, p); err != nil {
+		// Let a later import try again.
+		delete(in.modules, path)
+		return nil, err
+	}
+	return m, nil
+}
+
+// readModule reads the source of a module through the interpreter's file
+// system, so that imports are subject to the same restrictions as the file
+// functions.
+func (in *Interpreter) readModule(path string) (string, error) {
+	f, err := in.openFile(path, os.O_RDONLY)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	bytes, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// runModule runs the top-level statements of m, or on the VM the compiled
+// script p. Tracebacks show the module's top-level code as a frame called
+// from the import statement.
+func (in *Interpreter) runModule(m *Module, s *stmt.Import, statements []stmt.Stmt, p *proto) error {
+	module := in.module
+	in.module = m
+	in.importing = append(in.importing, m)
+
+	var err error
+	if in.backend == BackendVM {
+		closure := &Closure{proto: p, globals: m.globals}
+		_, err = in.vm.call(closure, closure, "", nil)
+	} else {
+		globals, env := in.globals, in.env
+		in.globals, in.env = m.globals, nil
+		in.frames = append(in.frames, callFrame{call: s.Path, env: in.env})
+		for _, st := range statements {
+			if err = in.Exec(st); err != nil {
+				break
+			}
+		}
+		if runtimeError, ok := err.(*Error); ok && runtimeError.Trace == nil {
+			runtimeError.Trace = in.stackTrace(runtimeError)
+		}
+		in.frames = in.frames[:len(in.frames)-1]
+		in.globals, in.env = globals, env
+	}
+
+	in.importing = in.importing[:len(in.importing)-1]
+	in.module = module
+	return err
+}
+
+func importChain(modules []*Module, m *Module) string {
+	var names []string
+	for _, module := range modules {
+		names = append(names, filepath.Base(module.path))
+	}
+	names = append(names, filepath.Base(m.path))
+	return strings.Join(names, " -> ")
+}
+
+func declarationName(s stmt.Stmt) string {
+	switch d := s.(type) {
+	case *stmt.Class:
+		return d.Name.Lexeme
+	case *stmt.Function:
+		return d.Name.Lexeme
+	case *stmt.Var:
+		return d.Name.Lexeme
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+package lox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runModules writes files to a temporary directory and runs main.lox from
+// it on both backends, returning what the first one wrote.
+func runModules(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr [2]string
+	for i, b := range backends {
+		var out, errs bytes.Buffer
+		in := NewInterpreter(WithStdout(&out), WithStderr(&errs), WithBackend(b.backend))
+		in.run(filepath.Join(dir, "main.lox"), files["main.lox"])
+		stdout[i], stderr[i] = out.String(), strings.ReplaceAll(errs.String(), dir, "DIR")
+	}
+	if stdout[0] != stdout[1] || stderr[0] != stderr[1] {
+		t.Errorf("backends disagree\ntree:\n%s%s\nvm:\n%s%s", stdout[0], stderr[0], stdout[1], stderr[1])
+	}
+	return stdout[0], stderr[0]
+}
+
+func TestImport(t *testing.T) {
+	stdout, stderr := runModules(t, map[string]string{
+		"main.lox": `import "lib/shapes.lox" as shapes;
+import "lib/shapes.lox" as again;
+print shapes.area(shapes.Square(3));
+print shapes.unit;
+print shapes;
+print again == shapes;
+`,
+		"lib/shapes.lox": `import "util.lox" as util;
+print "loading shapes";
+export class Square { init(side) { this.side = side; } }
+export fun area(s) { return util.square(s.side); }
+export var unit = "cm";
+var hidden = 1;
+`,
+		"lib/util.lox": "export fun square(n) { return n * n; }\n",
+	})
+	if stderr != "" {
+		t.Errorf("unexpected errors:\n%s", stderr)
+	}
+	want := "loading shapes\n9\ncm\n<module lib/shapes.lox>\ntrue\n"
+	if stdout != want {
+		t.Errorf("output:\n%s\nwant:\n%s", stdout, want)
+	}
+}
+
+func TestModulesHaveTheirOwnGlobals(t *testing.T) {
+	stdout, stderr := runModules(t, map[string]string{
+		"main.lox": `var name = "main";
+import "other.lox" as other;
+print name;
+print other.getName();
+other.setName("changed");
+print name;
+print other.getName();
+print other.describe(2);
+`,
+		"other.lox": `var name = "other";
+export fun getName() { return name; }
+export fun setName(n) { name = n; }
+export fun describe(n) { return "n=" + str(n); }
+`,
+	})
+	if stderr != "" {
+		t.Errorf("unexpected errors:\n%s", stderr)
+	}
+	want := "main\nother\nmain\nchanged\nn=2\n"
+	if stdout != want {
+		t.Errorf("output:\n%s\nwant:\n%s", stdout, want)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "missing module",
+			files: map[string]string{"main.lox": `import "missing.lox" as m;`},
+			want:  "Can't import 'missing.lox': no such file or directory",
+		},
+		{
+			name: "missing export",
+			files: map[string]string{
+				"main.lox": `import "m.lox" as m; print m.hidden;`,
+				"m.lox":    "var hidden = 1;",
+			},
+			want: "Module 'm.lox' has no export 'hidden'",
+		},
+		{
+			name: "assigning an export",
+			files: map[string]string{
+				"main.lox": `import "m.lox" as m; m.x = 2;`,
+				"m.lox":    "export var x = 1;",
+			},
+			want: "Can't assign to a module's exports",
+		},
+		{
+			name: "module with errors",
+			files: map[string]string{
+				"main.lox": `import "m.lox" as m;`,
+				"m.lox":    "print ;",
+			},
+			want: "[line 1 in DIR/m.lox] Error at ';': Expect expression.",
+		},
+		{
+			name: "circular import",
+			files: map[string]string{
+				"main.lox": `import "a.lox" as a;`,
+				"a.lox":    `import "b.lox" as b;`,
+				"b.lox":    `import "a.lox" as a;`,
+			},
+			want: "Circular import: a.lox -> b.lox -> a.lox",
+		},
+		{
+			name:  "import in a block",
+			files: map[string]string{"main.lox": `{ import "m.lox" as m; }`},
+			want:  "[line 1] Error at 'import': Can only import at the top level",
+		},
+		{
+			name:  "export in a function",
+			files: map[string]string{"main.lox": "fun f() { export var x = 1; }"},
+			want:  "[line 1] Error at 'export': Can only export top-level declarations",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, stderr := runModules(t, test.files)
+			if got, _, _ := strings.Cut(stderr, "\n"); got != test.want {
+				t.Errorf("error = %q, want %q\nfull output:\n%s", got, test.want, stderr)
+			}
+		})
+	}
+}
+
+// memFS is a read-only FileSystem holding files in memory.
+type memFS map[string]string
+
+func (fs memFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	source, ok := fs[name]
+	if !ok || flag != os.O_RDONLY {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return memFile{strings.NewReader(source)}, nil
+}
+
+type memFile struct {
+	*strings.Reader
+}
+
+func (memFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (memFile) Close() error {
+	return nil
+}
+
+func TestImportUsesFileSystem(t *testing.T) {
+	fs := memFS{"lib/greet.lox": `export fun greet(name) { return "hello " + name; }`}
+	for _, b := range backends {
+		stdout, stderr := runSource(t, `import "lib/greet.lox" as g; print g.greet("fs");`,
+			WithFileSystem(fs), WithBackend(b.backend))
+		if stdout != "hello fs\n" || stderr != "" {
+			t.Errorf("%s: got %q, %q", b.name, stdout, stderr)
+		}
+	}
+}
+
+func TestImportWithoutFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "m.lox")
+	if err := os.WriteFile(path, []byte("export var x = 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := "import \"" + filepath.ToSlash(path) + "\" as m; print m.x;"
+	expectError(t, source, "Can't import '"+filepath.ToSlash(path)+"': File access is disabled")
+}
@@ -0,0 +1,327 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"golox/lox/tok"
+	"math"
+	"reflect"
+)
+
+// Variadic is the arity of functions that accept any number of arguments.
+const Variadic = -1
+
+// NativeFunction is a Lox callable implemented in Go.
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    func(args []any) (any, error)
+}
+
+func NewNativeFunction(name string, arity int, fn func(args []any) (any, error)) *NativeFunction {
+	return &NativeFunction{
+		name:  name,
+		arity: arity,
+		fn:    fn,
+	}
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+func (n *NativeFunction) Call(in *Interpreter, arguments []any) (any, error) {
+	return n.fn(arguments)
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// nativeError turns an error returned by a native function into a runtime
+// error reported at the call site.
+func nativeError(err error, paren *tok.Token) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	return &Error{Token: paren, Message: err.Error()}
+}
+
+// DefineNative defines a global function implemented in Go. The arguments
+// passed to fn are Lox values, and it must return a Lox value. Pass
+// Variadic as the arity to accept any number of arguments. Like all
+// globals defined by the host, it is visible in every module.
+func (in *Interpreter) DefineNative(name string, arity int, fn func(args []any) (any, error)) {
+	in.builtins.Define(name, NewNativeFunction(name, arity, fn))
+}
+
+// Define defines a global variable visible in every module, converting
+// value to Lox as described by ToLox. A Go function is named after the
+// variable in error messages.
+func (in *Interpreter) Define(name string, value any) error {
+	var v any
+	var err error
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Func && !rv.IsNil() {
+		v, err = WrapFunc(name, value)
+	} else {
+		v, err = ToLox(value)
+	}
+	if err != nil {
+		return err
+	}
+	in.builtins.Define(name, v)
+	return nil
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// WrapFunc uses reflection to adapt an ordinary Go function to Lox.
+// Arguments are converted from Lox with FromLox, and the result with
+// ToLox. The function may return nothing, a value, an error, or a value
+// and an error. A variadic Go function becomes a variadic Lox function.
+func WrapFunc(name string, fn any) (*NativeFunction, error) {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s: expected a function but got %s", name, ft)
+	}
+
+	returnsError := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == errorType
+	results := ft.NumOut()
+	if returnsError {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("%s: functions can return at most one value and an error", name)
+	}
+
+	arity := ft.NumIn()
+	if ft.IsVariadic() {
+		arity = Variadic
+	}
+
+	return NewNativeFunction(name, arity, func(args []any) (any, error) {
+		if ft.IsVariadic() && len(args) < ft.NumIn()-1 {
+			return nil, fmt.Errorf("Expected at least %d arguments but got %d",
+				ft.NumIn()-1, len(args))
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var t reflect.Type
+			if ft.IsVariadic() && i >= ft.NumIn()-1 {
+				t = ft.In(ft.NumIn() - 1).Elem()
+			} else {
+				t = ft.In(i)
+			}
+			v, err := FromLox(arg, t)
+			if err != nil {
+				return nil, fmt.Errorf("%s: argument %d: %w", name, i+1, err)
+			}
+			in[i] = v
+		}
+
+		out := fv.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return nil, err
+			}
+		}
+		if results == 0 {
+			return nil, nil
+		}
+		return ToLox(out[0].Interface())
+	}), nil
+}
+
+// ToLox converts a Go value to a Lox value. Booleans and strings are kept
+// as they are, all numeric types become float64, functions are wrapped
+// with WrapFunc, structs and pointers to structs with WrapObject, slices
+// and arrays are copied into a List, and maps into a Map. Lox values and
+// any other Go values are passed through unchanged.
+func ToLox(value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch v := value.(type) {
+	case bool, string, float64, Callable, PropertyAccessor:
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Func:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return WrapFunc(rv.Type().String(), value)
+	case reflect.Struct:
+		return WrapObject(value)
+	case reflect.Slice, reflect.Array:
+		elements := make([]any, rv.Len())
+		for i := range elements {
+			e, err := ToLox(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = e
+		}
+		return NewList(elements), nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		m := NewMap()
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := ToLox(iter.Key().Interface())
+			if err != nil {
+				return nil, err
+			}
+			v, err := ToLox(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			if err := m.Put(k, v); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Elem().Kind() == reflect.Struct {
+			return WrapObject(value)
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// FromLox converts a Lox value to a Go value of type t, returning an error
+// if the value can't be represented.
+func FromLox(value any, t reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("expected %s but got nil", t)
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := value.(float64)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected a number but got %s", typeName(value))
+		}
+		if n != math.Trunc(n) || math.IsInf(n, 0) {
+			return reflect.Value{}, errors.New("expected an integer")
+		}
+		v := reflect.New(t).Elem()
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n < math.MinInt64 || n >= math.MaxInt64 || v.OverflowInt(int64(n)) {
+				return reflect.Value{}, fmt.Errorf("%s is out of range for %s", formatNumber(n), t)
+			}
+			v.SetInt(int64(n))
+		default:
+			if n < 0 || n >= math.MaxUint64 || v.OverflowUint(uint64(n)) {
+				return reflect.Value{}, fmt.Errorf("%s is out of range for %s", formatNumber(n), t)
+			}
+			v.SetUint(uint64(n))
+		}
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := value.(float64)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected a number but got %s", typeName(value))
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Slice:
+		if list, ok := value.(*List); ok {
+			s := reflect.MakeSlice(t, len(list.elements), len(list.elements))
+			for i, e := range list.elements {
+				v, err := FromLox(e, t.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+				}
+				s.Index(i).Set(v)
+			}
+			return s, nil
+		}
+	case reflect.Map:
+		if m, ok := value.(*Map); ok {
+			gm := reflect.MakeMapWithSize(t, len(m.keys))
+			for i, key := range m.keys {
+				k, err := FromLox(key, t.Key())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("key %s: %w", Repr(key), err)
+				}
+				v, err := FromLox(m.values[i], t.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("value for key %s: %w", Repr(key), err)
+				}
+				gm.SetMapIndex(k, v)
+			}
+			return gm, nil
+		}
+	}
+
+	rv := reflect.ValueOf(value)
+	if o, ok := value.(*GoObject); ok && !reflect.TypeOf(o).AssignableTo(t) {
+		rv = o.value
+	}
+	if rv.Type().AssignableTo(t) {
+		return rv, nil
+	}
+	if rv.Type().ConvertibleTo(t) && rv.Kind() == t.Kind() {
+		return rv.Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("expected %s but got %s", t, typeName(value))
+}
+
+// typeName describes the type of a Lox value for error messages.
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *Class:
+		return "class"
+	case *List:
+		return "list"
+	case *Map:
+		return "map"
+	case *ErrorObject:
+		return "error"
+	case PropertyAccessor:
+		return "instance"
+	case Callable:
+		return "function"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
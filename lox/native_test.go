@@ -0,0 +1,154 @@
+package lox
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWrapFunc(t *testing.T) {
+	add := func(a, b int) int { return a + b }
+	join := func(sep string, parts ...string) string { return strings.Join(parts, sep) }
+	sum := func(xs []float64) float64 {
+		total := 0.0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	}
+	fail := func() (string, error) { return "", errors.New("it failed") }
+
+	source := `print add(2, 3);
+print join("-", "a", "b", "c");
+print sum([1, 2, 3.5]);
+try { fail(); } catch (e) { print e.message; }`
+	for _, b := range backends {
+		var out strings.Builder
+		in := NewInterpreter(WithStdout(&out), WithStderr(&out), WithBackend(b.backend))
+		for name, fn := range map[string]any{"add": add, "join": join, "sum": sum, "fail": fail} {
+			if err := in.Define(name, fn); err != nil {
+				t.Fatal(err)
+			}
+		}
+		in.run("test.lox", source)
+		want := "5\na-b-c\n6.5\nit failed\n"
+		if out.String() != want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", b.name, out.String(), want)
+		}
+	}
+}
+
+func TestWrapFuncConversionErrors(t *testing.T) {
+	tests := []struct {
+		fn     any
+		source string
+		want   string
+	}{
+		{func(n uint8) {}, "f(300);", "f: argument 1: 300 is out of range for uint8"},
+		{func(n uint8) {}, "f(255);", ""},
+		{func(n int8) {}, "f(-129);", "f: argument 1: -129 is out of range for int8"},
+		{func(n int8) {}, "f(-128);", ""},
+		{func(n uint) {}, "f(-1);", "f: argument 1: -1 is out of range for uint"},
+		{func(n int64) {}, "f(100000000000000000000);", "f: argument 1: 100000000000000000000 is out of range for int64"},
+		{func(n int) {}, "f(1.5);", "f: argument 1: expected an integer"},
+		{func(n int) {}, "f(1 / 0);", "f: argument 1: expected an integer"},
+		{func(n int) {}, `f("1");`, "f: argument 1: expected a number but got string"},
+		{func(s string) {}, "f(nil);", "f: argument 1: expected string but got nil"},
+		{func(a, b int) {}, "f(1, 2, 3);", "Expected 2 arguments but got 3"},
+		{func(a int, rest ...int) {}, "f();", "Expected at least 1 arguments but got 0"},
+		{func(rest ...uint8) {}, "f(1, 2, 256);", "f: argument 3: 256 is out of range for uint8"},
+		{func(xs []int) {}, `f([1, "two"]);`, "f: argument 1: element 1: expected a number but got string"},
+		{func(m map[string]int) {}, `f({"a": 1.5});`, `f: argument 1: value for key "a": expected an integer`},
+	}
+	for _, test := range tests {
+		for _, b := range backends {
+			var stderr strings.Builder
+			in := NewInterpreter(WithStderr(&stderr), WithBackend(b.backend))
+			f, err := WrapFunc("f", test.fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in.builtins.Define("f", f)
+			in.run("test.lox", test.source)
+			got, _, _ := strings.Cut(stderr.String(), "\n")
+			if got != test.want {
+				t.Errorf("%s: %s with %T: got %q, want %q", b.name, test.source, test.fn, got, test.want)
+			}
+		}
+	}
+}
+
+func TestDefineNamesFunctions(t *testing.T) {
+	for _, b := range backends {
+		var stderr strings.Builder
+		in := NewInterpreter(WithStderr(&stderr), WithBackend(b.backend))
+		if err := in.Define("half", func(n int) int { return n / 2 }); err != nil {
+			t.Fatal(err)
+		}
+		in.run("test.lox", "half(1.5);")
+		got, _, _ := strings.Cut(stderr.String(), "\n")
+		if want := "half: argument 1: expected an integer"; got != want {
+			t.Errorf("%s: got %q, want %q", b.name, got, want)
+		}
+	}
+}
+
+func TestWrapFuncRejectsBadSignatures(t *testing.T) {
+	if _, err := WrapFunc("f", 42); err == nil {
+		t.Error("WrapFunc accepted a number")
+	}
+	if _, err := WrapFunc("f", func() (int, int) { return 0, 0 }); err == nil {
+		t.Error("WrapFunc accepted a function with two results")
+	}
+}
+
+func TestFromLox(t *testing.T) {
+	tests := []struct {
+		value any
+		typ   reflect.Type
+		want  any
+	}{
+		{3.0, reflect.TypeOf(int(0)), 3},
+		{255.0, reflect.TypeOf(uint8(0)), uint8(255)},
+		{2.5, reflect.TypeOf(float32(0)), float32(2.5)},
+		{"s", reflect.TypeOf(""), "s"},
+		{NewList([]any{1.0, 2.0}), reflect.TypeOf([]int{}), []int{1, 2}},
+		{nil, reflect.TypeOf([]int{}), []int(nil)},
+	}
+	for _, test := range tests {
+		v, err := FromLox(test.value, test.typ)
+		if err != nil {
+			t.Errorf("FromLox(%v, %s): %v", test.value, test.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(v.Interface(), test.want) {
+			t.Errorf("FromLox(%v, %s) = %#v, want %#v", test.value, test.typ, v.Interface(), test.want)
+		}
+	}
+}
+
+func TestToLox(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{int8(-3), "-3"},
+		{uint64(7), "7"},
+		{float32(0.5), "0.5"},
+		{[]string{"a", "b"}, `["a", "b"]`},
+		{map[string]int{"k": 1}, `{"k": 1}`},
+		{[]int(nil), "[]"},
+		{map[string]int(nil), "nil"},
+	}
+	for _, test := range tests {
+		v, err := ToLox(test.value)
+		if err != nil {
+			t.Errorf("ToLox(%#v): %v", test.value, err)
+			continue
+		}
+		if got := Repr(v); got != test.want {
+			t.Errorf("ToLox(%#v) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package lox
+
+import "testing"
+
+func TestPrintWritesToStdout(t *testing.T) {
+	stdout, stderr := runBoth(t, `print "hello"; print 1 + 2;`)
+	if stdout != "hello\n3\n" {
+		t.Errorf("stdout = %q, want %q", stdout, "hello\n3\n")
+	}
+	if stderr != "" {
+		t.Errorf("stderr = %q, want nothing", stderr)
+	}
+}
+
+func TestErrorsWriteToStderr(t *testing.T) {
+	stdout, stderr := runBoth(t, "print \"before\";\nprint -\"x\";\nprint \"after\";")
+	if stdout != "before\n" {
+		t.Errorf("stdout = %q, want %q", stdout, "before\n")
+	}
+	want := "operand must be a number\n[line 2]\n2 | print -\"x\";\n  |       ^\n"
+	if stderr != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+}
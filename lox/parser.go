@@ -7,8 +7,9 @@ import (
 )
 
 type Parser struct {
-	tokens  []*tok.Token
-	current int
+	tokens      []*tok.Token
+	diagnostics []*Diagnostic
+	current     int
 }
 
 func NewParser(tokens []*tok.Token) *Parser {
@@ -25,11 +26,19 @@ func (p *Parser) Parse() []stmt.Stmt {
 	return statements
 }
 
+func (p *Parser) Diagnostics() []*Diagnostic {
+	return p.diagnostics
+}
+
 func (p *Parser) declaration() stmt.Stmt {
 	var s stmt.Stmt
 	var err error
 
-	if p.match(tok.Class) {
+	if p.match(tok.Export) {
+		s, err = p.exportDeclaration()
+	} else if p.match(tok.Import) {
+		s, err = p.importStatement()
+	} else if p.match(tok.Class) {
 		s, err = p.classDeclaration()
 	} else if p.match(tok.Fun) {
 		s, err = p.function("function")
@@ -50,6 +59,48 @@ func (p *Parser) declaration() stmt.Stmt {
 	return s
 }
 
+func (p *Parser) exportDeclaration() (stmt.Stmt, error) {
+	keyword := p.previous()
+
+	var declaration stmt.Stmt
+	var err error
+	if p.match(tok.Class) {
+		declaration, err = p.classDeclaration()
+	} else if p.match(tok.Fun) {
+		declaration, err = p.function("function")
+	} else if p.match(tok.Var) {
+		declaration, err = p.varDeclaration()
+	} else {
+		return nil, p.error(p.peek(), CodeExpectedToken, "Expect declaration after 'export'")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt.Export{Keyword: keyword, Declaration: declaration}, nil
+}
+
+func (p *Parser) importStatement() (stmt.Stmt, error) {
+	keyword := p.previous()
+	path, err := p.consume(tok.String, "Expect module path after 'import'")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(tok.As, "Expect 'as' after module path")
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.consume(tok.Identifier, "Expect module name after 'as'")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(tok.Semicolon, "Expect ';' after import")
+	if err != nil {
+		return nil, err
+	}
+	return &stmt.Import{Keyword: keyword, Path: path, Name: name}, nil
+}
+
 func (p *Parser) classDeclaration() (stmt.Stmt, error) {
 	name, err := p.consume(tok.Identifier, "Expect class name")
 	if err != nil {
@@ -102,18 +153,47 @@ func (p *Parser) statement() (stmt.Stmt, error) {
 		return p.printStatement()
 	} else if p.match(tok.Return) {
 		return p.returnStatement()
+	} else if p.match(tok.Throw) {
+		keyword := p.previous()
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.consume(tok.Semicolon, "Expect ';' after thrown value")
+		if err != nil {
+			return nil, err
+		}
+		return &stmt.Throw{Keyword: keyword, Value: value}, nil
+	} else if p.match(tok.Try) {
+		return p.tryStatement()
+	} else if p.match(tok.Break) {
+		keyword := p.previous()
+		_, err := p.consume(tok.Semicolon, "Expect ';' after 'break'")
+		if err != nil {
+			return nil, err
+		}
+		return &stmt.Break{Keyword: keyword}, nil
+	} else if p.match(tok.Continue) {
+		keyword := p.previous()
+		_, err := p.consume(tok.Semicolon, "Expect ';' after 'continue'")
+		if err != nil {
+			return nil, err
+		}
+		return &stmt.Continue{Keyword: keyword}, nil
 	} else if p.match(tok.LeftBrace) {
+		brace := p.previous()
 		block, err := p.block()
 		if err != nil {
 			return nil, err
 		}
-		return &stmt.Block{Statements: block}, nil
+		return &stmt.Block{Brace: brace, Statements: block}, nil
 	} else {
 		return p.expressionStatement()
 	}
 }
 
 func (p *Parser) ifStatement() (stmt.Stmt, error) {
+	keyword := p.previous()
 	_, err := p.consume(tok.LeftParen, "Expect '(' after 'if'")
 	if err != nil {
 		return nil, err
@@ -138,12 +218,14 @@ func (p *Parser) ifStatement() (stmt.Stmt, error) {
 		}
 	}
 	return &stmt.If{
+		Keyword:    keyword,
 		Condition:  condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch}, nil
 }
 
 func (p *Parser) whileStatement() (stmt.Stmt, error) {
+	keyword := p.previous()
 	_, err := p.consume(tok.LeftParen, "Expect '(' after 'while'")
 	if err != nil {
 		return nil, err
@@ -160,10 +242,11 @@ func (p *Parser) whileStatement() (stmt.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &stmt.While{Condition: condition, Body: body}, nil
+	return &stmt.While{Keyword: keyword, Condition: condition, Body: body}, nil
 }
 
 func (p *Parser) forStatement() (stmt.Stmt, error) {
+	keyword := p.previous()
 	_, err := p.consume(tok.LeftParen, "Expect '(' after 'for'")
 	if err != nil {
 		return nil, err
@@ -215,22 +298,15 @@ func (p *Parser) forStatement() (stmt.Stmt, error) {
 		return nil, err
 	}
 
-	if increment != nil {
-		body = &stmt.Block{
-			Statements: []stmt.Stmt{
-				body,
-				&stmt.Expression{Expression: increment},
-			},
-		}
-	}
-
 	if condition == nil {
 		condition = &expr.Literal{Value: true}
 	}
 
 	body = &stmt.While{
+		Keyword:   keyword,
 		Condition: condition,
 		Body:      body,
+		Increment: increment,
 	}
 
 	if initializer != nil {
@@ -245,7 +321,62 @@ func (p *Parser) forStatement() (stmt.Stmt, error) {
 	return body, nil
 }
 
+func (p *Parser) tryStatement() (stmt.Stmt, error) {
+	s := &stmt.Try{Keyword: p.previous()}
+
+	var err error
+	s.Body, err = p.blockStatement("try")
+	if err != nil {
+		return nil, err
+	}
+
+	if p.match(tok.Catch) {
+		_, err = p.consume(tok.LeftParen, "Expect '(' after 'catch'")
+		if err != nil {
+			return nil, err
+		}
+		s.CatchName, err = p.consume(tok.Identifier, "Expect variable name")
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.consume(tok.RightParen, "Expect ')' after catch variable")
+		if err != nil {
+			return nil, err
+		}
+		s.Catch, err = p.blockStatement("catch")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.match(tok.Finally) {
+		s.Finally, err = p.blockStatement("finally")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Catch == nil && s.Finally == nil {
+		return nil, p.error(p.peek(), CodeExpectedToken, "Expect 'catch' or 'finally' after try block")
+	}
+
+	return s, nil
+}
+
+func (p *Parser) blockStatement(keyword string) (*stmt.Block, error) {
+	brace, err := p.consume(tok.LeftBrace, "Expect '{' after '"+keyword+"'")
+	if err != nil {
+		return nil, err
+	}
+	statements, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	return &stmt.Block{Brace: brace, Statements: statements}, nil
+}
+
 func (p *Parser) printStatement() (stmt.Stmt, error) {
+	keyword := p.previous()
 	value, err := p.expression()
 	if err != nil {
 		return nil, err
@@ -254,7 +385,7 @@ func (p *Parser) printStatement() (stmt.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &stmt.Print{Expression: value}, nil
+	return &stmt.Print{Keyword: keyword, Expression: value}, nil
 }
 
 func (p *Parser) varDeclaration() (stmt.Stmt, error) {
@@ -323,7 +454,7 @@ func (p *Parser) function(kind string) (*stmt.Function, error) {
 	if !p.check(tok.RightParen) {
 		for {
 			if len(params) >= 255 {
-				return nil, p.error(p.peek(), "Can't have more than 255 parameters")
+				return nil, p.error(p.peek(), CodeTooManyParameters, "Can't have more than 255 parameters")
 			}
 
 			param, err := p.consume(tok.Identifier, "Expect parameter name")
@@ -406,7 +537,17 @@ func (p *Parser) assignment() (expr.Expr, error) {
 			}, nil
 		}
 
-		return nil, &Error{Token: equals, Message: "Invalid assignment target"}
+		indexExpr, ok := e.(*expr.Index)
+		if ok {
+			return &expr.SetIndex{
+				Object:  indexExpr.Object,
+				Bracket: indexExpr.Bracket,
+				Index:   indexExpr.Index,
+				Value:   value,
+			}, nil
+		}
+
+		return nil, p.error(equals, CodeInvalidAssignment, "Invalid assignment target")
 	}
 
 	return e, nil
@@ -540,6 +681,21 @@ func (p *Parser) call() (expr.Expr, error) {
 				Object: e,
 				Name:   name,
 			}
+		} else if p.match(tok.LeftBracket) {
+			bracket := p.previous()
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			_, err = p.consume(tok.RightBracket, "Expect ']' after index")
+			if err != nil {
+				return nil, err
+			}
+			e = &expr.Index{
+				Object:  e,
+				Bracket: bracket,
+				Index:   index,
+			}
 		} else {
 			break
 		}
@@ -552,7 +708,7 @@ func (p *Parser) finishCall(callee expr.Expr) (expr.Expr, error) {
 	if !p.check(tok.RightParen) {
 		for {
 			if len(arguments) >= 255 {
-				_ = p.error(p.peek(), "Can't have more than 255 arguments")
+				_ = p.error(p.peek(), CodeTooManyArguments, "Can't have more than 255 arguments")
 			}
 			arg, err := p.expression()
 			if err != nil {
@@ -579,13 +735,13 @@ func (p *Parser) finishCall(callee expr.Expr) (expr.Expr, error) {
 
 func (p *Parser) primary() (expr.Expr, error) {
 	if p.match(tok.False) {
-		return &expr.Literal{Value: false}, nil
+		return &expr.Literal{Token: p.previous(), Value: false}, nil
 	} else if p.match(tok.True) {
-		return &expr.Literal{Value: true}, nil
+		return &expr.Literal{Token: p.previous(), Value: true}, nil
 	} else if p.match(tok.Nil) {
-		return &expr.Literal{Value: nil}, nil
+		return &expr.Literal{Token: p.previous(), Value: nil}, nil
 	} else if p.match(tok.Number, tok.String) {
-		return &expr.Literal{Value: p.previous().Literal}, nil
+		return &expr.Literal{Token: p.previous(), Value: p.previous().Literal}, nil
 	} else if p.match(tok.Super) {
 		keyword := p.previous()
 		_, err := p.consume(tok.Dot, "Expect '.' after 'super'")
@@ -601,7 +757,14 @@ func (p *Parser) primary() (expr.Expr, error) {
 		return &expr.This{Keyword: p.previous()}, nil
 	} else if p.match(tok.Identifier) {
 		return &expr.Variable{Name: p.previous()}, nil
+	} else if p.match(tok.LeftBracket) {
+		return p.list()
+	} else if p.match(tok.LeftBrace) {
+		// A '{' at the start of a statement begins a block, so a map literal
+		// can only appear where an expression is expected.
+		return p.mapLiteral()
 	} else if p.match(tok.LeftParen) {
+		paren := p.previous()
 		e, err := p.expression()
 		if err != nil {
 			return nil, err
@@ -610,10 +773,67 @@ func (p *Parser) primary() (expr.Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		return &expr.Grouping{Expression: e}, nil
+		return &expr.Grouping{Paren: paren, Expression: e}, nil
+	}
+
+	return nil, p.error(p.peek(), CodeExpectedExpression, "Expect expression.")
+}
+
+func (p *Parser) list() (expr.Expr, error) {
+	bracket := p.previous()
+	var elements []expr.Expr
+	if !p.check(tok.RightBracket) {
+		for {
+			e, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, e)
+			if !p.match(tok.Comma) || p.check(tok.RightBracket) {
+				break
+			}
+		}
+	}
+
+	_, err := p.consume(tok.RightBracket, "Expect ']' after list elements")
+	if err != nil {
+		return nil, err
+	}
+
+	return &expr.List{Bracket: bracket, Elements: elements}, nil
+}
+
+func (p *Parser) mapLiteral() (expr.Expr, error) {
+	brace := p.previous()
+	var keys, values []expr.Expr
+	if !p.check(tok.RightBrace) {
+		for {
+			key, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			_, err = p.consume(tok.Colon, "Expect ':' after map key")
+			if err != nil {
+				return nil, err
+			}
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			values = append(values, value)
+			if !p.match(tok.Comma) || p.check(tok.RightBrace) {
+				break
+			}
+		}
+	}
+
+	_, err := p.consume(tok.RightBrace, "Expect '}' after map entries")
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, p.error(p.peek(), "Expect expression.")
+	return &expr.Map{Brace: brace, Keys: keys, Values: values}, nil
 }
 
 func (p *Parser) match(ts ...tok.Type) bool {
@@ -630,7 +850,7 @@ func (p *Parser) consume(t tok.Type, message string) (*tok.Token, error) {
 	if p.check(t) {
 		return p.advance(), nil
 	}
-	return nil, p.error(p.peek(), message)
+	return nil, p.error(p.peek(), CodeExpectedToken, message)
 }
 
 func (p *Parser) check(t tok.Type) bool {
@@ -659,12 +879,13 @@ func (p *Parser) previous() *tok.Token {
 	return p.tokens[p.current-1]
 }
 
-func (p *Parser) error(tok *tok.Token, message string) *Error {
+func (p *Parser) error(tok *tok.Token, code Code, message string) *Error {
 	err := &Error{
 		Token:   tok,
+		Code:    code,
 		Message: message,
 	}
-	ReportParseError(err)
+	p.diagnostics = append(p.diagnostics, newTokenDiagnostic(PhaseParse, err))
 	return err
 }
 
@@ -677,7 +898,8 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().Type {
-		case tok.Class, tok.Fun, tok.Var, tok.For, tok.If, tok.While, tok.Print, tok.Return:
+		case tok.Class, tok.Fun, tok.Var, tok.For, tok.If, tok.While, tok.Print, tok.Return,
+			tok.Break, tok.Continue, tok.Throw, tok.Try, tok.Import, tok.Export:
 			return
 		}
 
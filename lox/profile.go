@@ -0,0 +1,266 @@
+package lox
+
+import (
+	"fmt"
+	"golox/lox/stmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FunctionProfile is the time spent in one Lox function. Total includes
+// the time spent in the functions it calls, and Self doesn't.
+type FunctionProfile struct {
+	Name  string
+	File  string
+	Line  int
+	Calls int
+	Total time.Duration
+	Self  time.Duration
+	// active counts the calls in progress, so recursive calls aren't
+	// counted twice in Total.
+	active int
+}
+
+func (f *FunctionProfile) label() string {
+	return fmt.Sprintf("%s (%s:%d)", f.Name, f.File, f.Line)
+}
+
+// LineProfile is the number of statements run on one source line.
+type LineProfile struct {
+	File string
+	Line int
+	Hits int
+}
+
+type lineKey struct {
+	file string
+	line int
+}
+
+// activation is a call in progress.
+type activation struct {
+	function *FunctionProfile
+	start    time.Time
+	children time.Duration
+	stack    *stackNode
+}
+
+// stackNode is a distinct call stack: a call to function from the stack
+// parent. The root node, with no function, is the script itself.
+type stackNode struct {
+	parent   *stackNode
+	function *FunctionProfile
+	self     time.Duration
+}
+
+type stackKey struct {
+	parent   *stackNode
+	function *FunctionProfile
+}
+
+// Profiler records how much time the tree-walking interpreter spends in
+// each function, and how often it runs each line.
+type Profiler struct {
+	start     time.Time
+	elapsed   time.Duration
+	functions map[*stmt.Function]*FunctionProfile
+	lines     map[lineKey]int
+	stack     []activation
+	// stacks holds the call stacks other than the script's, which form a
+	// tree rooted at root.
+	root    *stackNode
+	stacks  map[stackKey]*stackNode
+	sources map[string]string
+}
+
+const scriptFrame = "<script>"
+
+// NewProfiler returns a profiler that starts timing the script at once.
+func NewProfiler() *Profiler {
+	now := time.Now()
+	root := &stackNode{}
+	return &Profiler{
+		start:     now,
+		functions: make(map[*stmt.Function]*FunctionProfile),
+		lines:     make(map[lineKey]int),
+		stack:     []activation{{start: now, stack: root}},
+		root:      root,
+		stacks:    make(map[stackKey]*stackNode),
+	}
+}
+
+// Stop ends the profile. The time spent outside any function is counted
+// against the script itself.
+func (p *Profiler) Stop() {
+	now := time.Now()
+	p.elapsed = now.Sub(p.start)
+	root := p.stack[0]
+	root.stack.self += now.Sub(root.start) - root.children
+}
+
+func (p *Profiler) enter(declaration *stmt.Function) {
+	f, ok := p.functions[declaration]
+	if !ok {
+		f = &FunctionProfile{
+			Name: declaration.Name.Lexeme,
+			File: declaration.Name.File,
+			Line: declaration.Name.Line,
+		}
+		p.functions[declaration] = f
+	}
+	f.Calls++
+	f.active++
+	key := stackKey{parent: p.stack[len(p.stack)-1].stack, function: f}
+	node, ok := p.stacks[key]
+	if !ok {
+		node = &stackNode{parent: key.parent, function: f}
+		p.stacks[key] = node
+	}
+	p.stack = append(p.stack, activation{
+		function: f,
+		start:    time.Now(),
+		stack:    node,
+	})
+}
+
+func (p *Profiler) exit() {
+	now := time.Now()
+	a := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	elapsed := now.Sub(a.start)
+	self := elapsed - a.children
+	p.stack[len(p.stack)-1].children += elapsed
+
+	a.function.active--
+	if a.function.active == 0 {
+		a.function.Total += elapsed
+	}
+	a.function.Self += self
+	a.stack.self += self
+}
+
+func (p *Profiler) hit(s stmt.Stmt) {
+	if _, ok := s.(*stmt.Block); ok {
+		return
+	}
+	if t := stmt.FirstToken(s); t != nil {
+		p.lines[lineKey{t.File, t.Line}]++
+	}
+}
+
+// Functions returns the profile of each function that was called, sorted
+// by self time, highest first.
+func (p *Profiler) Functions() []*FunctionProfile {
+	var functions []*FunctionProfile
+	for _, f := range p.functions {
+		functions = append(functions, f)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].Self != functions[j].Self {
+			return functions[i].Self > functions[j].Self
+		}
+		return functions[i].label() < functions[j].label()
+	})
+	return functions
+}
+
+// Lines returns the hit count of each line that ran, sorted by count,
+// highest first.
+func (p *Profiler) Lines() []LineProfile {
+	var lines []LineProfile
+	for key, hits := range p.lines {
+		lines = append(lines, LineProfile{File: key.file, Line: key.line, Hits: hits})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Hits != lines[j].Hits {
+			return lines[i].Hits > lines[j].Hits
+		}
+		if lines[i].File != lines[j].File {
+			return lines[i].File < lines[j].File
+		}
+		return lines[i].Line < lines[j].Line
+	})
+	return lines
+}
+
+// maxReportLines is how many of the busiest lines WriteReport shows.
+const maxReportLines = 20
+
+// WriteReport writes the profile as a table of functions followed by the
+// busiest lines.
+func (p *Profiler) WriteReport(w io.Writer) {
+	fmt.Fprintf(w, "Total time: %s\n\n", formatDuration(p.elapsed))
+	fmt.Fprintf(w, "%10s %12s %7s %12s %7s  %s\n", "calls", "total", "%", "self", "%", "function")
+	for _, f := range p.Functions() {
+		fmt.Fprintf(w, "%10d %12s %6.1f%% %12s %6.1f%%  %s\n",
+			f.Calls,
+			formatDuration(f.Total), p.percent(f.Total),
+			formatDuration(f.Self), p.percent(f.Self),
+			f.label())
+	}
+
+	lines := p.Lines()
+	fmt.Fprintf(w, "\n%10s  %s\n", "hits", "line")
+	for i, l := range lines {
+		if i == maxReportLines {
+			fmt.Fprintf(w, "%10s  (%d more lines)\n", "...", len(lines)-i)
+			break
+		}
+		fmt.Fprintf(w, "%10d  %s:%d", l.Hits, l.File, l.Line)
+		if text, ok := p.sourceLine(l.File, l.Line); ok {
+			fmt.Fprintf(w, "  %s", text)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// WriteFolded writes the profile in the folded stack format read by
+// flame graph tools: one line per call stack, with the functions from the
+// outermost in, separated by semicolons, followed by the self time in
+// microseconds.
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	names := map[*stackNode]string{p.root: scriptFrame}
+	var name func(n *stackNode) string
+	name = func(n *stackNode) string {
+		s, ok := names[n]
+		if !ok {
+			s = name(n.parent) + ";" + n.function.label()
+			names[n] = s
+		}
+		return s
+	}
+	stacks := []*stackNode{p.root}
+	for _, node := range p.stacks {
+		stacks = append(stacks, node)
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		return name(stacks[i]) < name(stacks[j])
+	})
+	for _, node := range stacks {
+		if _, err := fmt.Fprintf(w, "%s %d\n", name(node), node.self.Microseconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Profiler) percent(d time.Duration) float64 {
+	if p.elapsed == 0 {
+		return 0
+	}
+	return 100 * float64(d) / float64(p.elapsed)
+}
+
+func (p *Profiler) sourceLine(file string, line int) (string, bool) {
+	lines := strings.Split(p.sources[file], "\n")
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	return strings.TrimSpace(lines[line-1]), true
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
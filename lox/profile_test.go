@@ -0,0 +1,174 @@
+package lox
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const profileScript = `fun leaf(n) {
+  var s = 0;
+  for (var i = 0; i < n; i = i + 1)
+    s = s + i;
+  return s;
+}
+fun caller() {
+  return leaf(10) + leaf(20);
+}
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+caller();
+caller();
+print fib(10);
+`
+
+func runProfile(t *testing.T) *Profiler {
+	t.Helper()
+	var out, errs bytes.Buffer
+	p := NewProfiler()
+	in := NewInterpreter(WithProfiler(p), WithStdout(&out), WithStderr(&errs))
+	in.run("test.lox", profileScript)
+	p.Stop()
+	if out.String() != "55\n" || errs.Len() > 0 {
+		t.Fatalf("script printed %q, %q", out.String(), errs.String())
+	}
+	return p
+}
+
+func TestProfileFunctions(t *testing.T) {
+	p := runProfile(t)
+	functions := make(map[string]*FunctionProfile)
+	for _, f := range p.Functions() {
+		functions[f.Name] = f
+	}
+
+	calls := map[string]int{"leaf": 4, "caller": 2, "fib": 177}
+	lines := map[string]int{"leaf": 1, "caller": 7, "fib": 10}
+	for name, want := range calls {
+		f := functions[name]
+		if f == nil {
+			t.Errorf("no profile for %s", name)
+			continue
+		}
+		if f.Calls != want {
+			t.Errorf("%s was called %d times, want %d", name, f.Calls, want)
+		}
+		if f.File != "test.lox" || f.Line != lines[name] {
+			t.Errorf("%s is at %s:%d, want test.lox:%d", name, f.File, f.Line, lines[name])
+		}
+	}
+	if len(functions) != len(calls) {
+		t.Errorf("profiled %d functions, want %d", len(functions), len(calls))
+	}
+
+	leaf, caller, fib := functions["leaf"], functions["caller"], functions["fib"]
+	// A function that calls nothing spends all its time in itself.
+	if leaf.Self != leaf.Total {
+		t.Errorf("leaf: self %v != total %v", leaf.Self, leaf.Total)
+	}
+	// caller's time is its own plus leaf's.
+	if caller.Total != caller.Self+leaf.Total {
+		t.Errorf("caller: total %v != self %v + leaf's total %v", caller.Total, caller.Self, leaf.Total)
+	}
+	// Recursive calls aren't counted twice in the total.
+	if fib.Self != fib.Total {
+		t.Errorf("fib: self %v != total %v", fib.Self, fib.Total)
+	}
+	if caller.Total+fib.Total > p.elapsed {
+		t.Errorf("functions took %v, longer than the script's %v", caller.Total+fib.Total, p.elapsed)
+	}
+
+	// Functions are sorted by self time.
+	sorted := p.Functions()
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Self > sorted[i-1].Self {
+			t.Errorf("%s is listed after %s but has more self time", sorted[i].Name, sorted[i-1].Name)
+		}
+	}
+}
+
+func TestProfileLines(t *testing.T) {
+	hits := make(map[int]int)
+	for _, l := range runProfile(t).Lines() {
+		if l.File != "test.lox" {
+			t.Errorf("line in %q", l.File)
+		}
+		hits[l.Line] = l.Hits
+	}
+	// Hits count statements, so a line with several counts each of them.
+	want := map[int]int{
+		1:  1,   // fun leaf
+		2:  4,   // var s = 0;
+		3:  8,   // the for loop's initializer and loop
+		4:  60,  // s = s + i;
+		5:  4,   // return s;
+		7:  1,   // fun caller
+		8:  2,   // return leaf(10) + leaf(20);
+		10: 1,   // fun fib
+		11: 266, // 177 ifs and 89 returns
+		12: 88,  // return fib(n - 1) + fib(n - 2);
+		14: 1,
+		15: 1,
+		16: 1,
+	}
+	if len(hits) != len(want) {
+		t.Errorf("%d lines ran, want %d", len(hits), len(want))
+	}
+	for line, n := range want {
+		if hits[line] != n {
+			t.Errorf("line %d ran %d times, want %d", line, hits[line], n)
+		}
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	var out bytes.Buffer
+	if err := runProfile(t).WriteFolded(&out); err != nil {
+		t.Fatal(err)
+	}
+	format := regexp.MustCompile(`^<script>(;\w+ \(test\.lox:\d+\))* \d+$`)
+	stacks := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if !format.MatchString(line) {
+			t.Errorf("bad folded line %q", line)
+			continue
+		}
+		stacks[line[:strings.LastIndexByte(line, ' ')]] = true
+	}
+	for _, want := range []string{
+		"<script>",
+		"<script>;caller (test.lox:7)",
+		"<script>;caller (test.lox:7);leaf (test.lox:1)",
+		"<script>;fib (test.lox:10)",
+		"<script>;fib (test.lox:10);fib (test.lox:10)",
+	} {
+		if !stacks[want] {
+			t.Errorf("no folded stack %q in\n%s", want, out.String())
+		}
+	}
+	if stacks["<script>;leaf (test.lox:1)"] {
+		t.Error("leaf is only called from caller")
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var out bytes.Buffer
+	runProfile(t).WriteReport(&out)
+	report := out.String()
+	for _, want := range []string{
+		"Total time: ",
+		"     calls        total       %         self       %  function\n",
+		"       177 ",
+		"  fib (test.lox:10)\n",
+		"\n      hits  line\n",
+		"       266  test.lox:11  if (n < 2) return n;\n",
+		"        60  test.lox:4  s = s + i;\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+}
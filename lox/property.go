@@ -0,0 +1,177 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"golox/lox/tok"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PropertyAccessor is implemented by values that have properties, which
+// Lox code reads with "object.name" and writes with "object.name = value".
+// Errors are reported as runtime errors at the property name.
+type PropertyAccessor interface {
+	GetProperty(name string) (any, error)
+	SetProperty(name string, value any) error
+}
+
+func undefinedProperty(name string) error {
+	return errors.New("Undefined property '" + name + "'")
+}
+
+func getProperty(object any, name *tok.Token) (any, error) {
+	if s, ok := object.(string); ok {
+		value, err := stringProperty(s, name.Lexeme)
+		return value, nativeError(err, name)
+	}
+
+	accessor, ok := object.(PropertyAccessor)
+	if !ok {
+		return nil, &Error{
+			Token:   name,
+			Message: "Only instances have properties",
+		}
+	}
+
+	value, err := accessor.GetProperty(name.Lexeme)
+	return value, nativeError(err, name)
+}
+
+func setProperty(object any, name *tok.Token, value any) error {
+	accessor, ok := object.(PropertyAccessor)
+	if !ok {
+		return &Error{
+			Token:   name,
+			Message: "Only instances have fields",
+		}
+	}
+
+	return nativeError(accessor.SetProperty(name.Lexeme, value), name)
+}
+
+// Indexer is implemented by values that can be indexed with "object[index]".
+type Indexer interface {
+	GetIndex(index any) (any, error)
+	SetIndex(index any, value any) error
+}
+
+func getIndex(object any, index any, bracket *tok.Token) (any, error) {
+	indexer, ok := object.(Indexer)
+	if !ok {
+		return nil, &Error{
+			Token:   bracket,
+			Message: "Only lists and maps can be indexed",
+		}
+	}
+
+	value, err := indexer.GetIndex(index)
+	return value, nativeError(err, bracket)
+}
+
+func setIndex(object any, index any, bracket *tok.Token, value any) error {
+	indexer, ok := object.(Indexer)
+	if !ok {
+		return &Error{
+			Token:   bracket,
+			Message: "Only lists and maps can be indexed",
+		}
+	}
+
+	return nativeError(indexer.SetIndex(index, value), bracket)
+}
+
+// GoObject exposes a Go struct to Lox through reflection. Exported fields
+// can be read and, if the struct was passed by pointer, assigned; exported
+// methods can be called. A property name matches a field with the same
+// name or the same name capitalized, or a field tagged `lox:"name"`.
+type GoObject struct {
+	value reflect.Value
+}
+
+// WrapObject wraps a struct or a pointer to a struct.
+func WrapObject(v any) (*GoObject, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Struct {
+		return &GoObject{value: rv}, nil
+	}
+	if rv.Kind() == reflect.Struct {
+		return &GoObject{value: rv}, nil
+	}
+	return nil, fmt.Errorf("expected a struct or a pointer to a struct but got %T", v)
+}
+
+// Value returns the wrapped Go value.
+func (o *GoObject) Value() any {
+	return o.value.Interface()
+}
+
+func (o *GoObject) String() string {
+	return reflect.Indirect(o.value).Type().Name() + " instance"
+}
+
+func (o *GoObject) GetProperty(name string) (any, error) {
+	field, ok, err := o.field(name)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return ToLox(field.Interface())
+	}
+
+	for _, n := range []string{name, capitalize(name)} {
+		if method := o.value.MethodByName(n); method.IsValid() {
+			return WrapFunc(name, method.Interface())
+		}
+	}
+
+	return nil, undefinedProperty(name)
+}
+
+func (o *GoObject) SetProperty(name string, value any) error {
+	field, ok, err := o.field(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return undefinedProperty(name)
+	}
+	if !field.CanSet() {
+		return fmt.Errorf("Can't assign to field '%s' of a Go value passed by copy", name)
+	}
+
+	v, err := FromLox(value, field.Type())
+	if err != nil {
+		return fmt.Errorf("Can't assign to field '%s': %w", name, err)
+	}
+	field.Set(v)
+	return nil
+}
+
+// field finds the field for a property. It fails if the field is promoted
+// from an embedded struct through a nil pointer.
+func (o *GoObject) field(name string) (reflect.Value, bool, error) {
+	s := reflect.Indirect(o.value)
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("lox") == name && t.Field(i).IsExported() {
+			return s.Field(i), true, nil
+		}
+	}
+	for _, n := range []string{name, capitalize(name)} {
+		if f, ok := t.FieldByName(n); ok && f.IsExported() {
+			field, err := s.FieldByIndexErr(f.Index)
+			if err != nil {
+				return reflect.Value{}, false, fmt.Errorf("Can't access field '%s' through a nil embedded pointer", name)
+			}
+			return field, true, nil
+		}
+	}
+	return reflect.Value{}, false, nil
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
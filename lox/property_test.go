@@ -0,0 +1,105 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testPoint struct {
+	X, Y   float64
+	Label  string `lox:"name"`
+	hidden int
+}
+
+func (p *testPoint) Move(dx, dy float64) {
+	p.X += dx
+	p.Y += dy
+}
+
+func (p testPoint) String() string {
+	return fmt.Sprintf("(%g, %g)", p.X, p.Y)
+}
+
+// runWithGlobals runs source on each backend with the globals defined by
+// define, and checks that its output, followed by any errors, starts with
+// want.
+func runWithGlobals(t *testing.T, source string, want string, define func(in *Interpreter)) {
+	t.Helper()
+	for _, b := range backends {
+		var out strings.Builder
+		in := NewInterpreter(WithStdout(&out), WithStderr(&out), WithBackend(b.backend))
+		define(in)
+		in.run("test.lox", source)
+		if got := out.String(); !strings.HasPrefix(got, want) {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", b.name, got, want)
+		}
+	}
+}
+
+func TestGoObjectByPointer(t *testing.T) {
+	p := &testPoint{X: 1, Y: 2, Label: "origin"}
+	source := `print p.x + p.Y;
+print p.name;
+p.x = 10;
+p.move(1, 1);
+print p.string();
+print p;`
+	runWithGlobals(t, source, "3\norigin\n(11, 3)\ntestPoint instance\n", func(in *Interpreter) {
+		p.X, p.Y = 1, 2
+		if err := in.Define("p", p); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if p.X != 11 || p.Y != 3 {
+		t.Errorf("Go value is (%g, %g), want (11, 3)", p.X, p.Y)
+	}
+}
+
+func TestGoObjectByValue(t *testing.T) {
+	source := `print p.x;
+p.x = 5;`
+	want := "1\nCan't assign to field 'x' of a Go value passed by copy\n"
+	runWithGlobals(t, source, want, func(in *Interpreter) {
+		in.Define("p", testPoint{X: 1})
+	})
+}
+
+func TestGoObjectErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print p.hidden;", "Undefined property 'hidden'"},
+		{"print p.missing;", "Undefined property 'missing'"},
+		{`p.x = "text";`, "Can't assign to field 'x': expected a number but got string"},
+		{`p.move("a", 1);`, "move: argument 1: expected a number but got string"},
+	}
+	for _, test := range tests {
+		runWithGlobals(t, test.source, test.want+"\n", func(in *Interpreter) {
+			in.Define("p", &testPoint{})
+		})
+	}
+}
+
+type testLabeled struct {
+	*testPoint
+	Size float64
+}
+
+func TestGoObjectNilEmbeddedPointer(t *testing.T) {
+	source := `print l.size;
+print l.x;`
+	want := "2\nCan't access field 'x' through a nil embedded pointer\n"
+	runWithGlobals(t, source, want, func(in *Interpreter) {
+		in.Define("l", &testLabeled{Size: 2})
+	})
+}
+
+func TestWrapObjectRejectsNonStructs(t *testing.T) {
+	for _, v := range []any{42, "s", (*testPoint)(nil), []int{}} {
+		if _, err := WrapObject(v); err == nil {
+			t.Errorf("WrapObject(%#v) succeeded", v)
+		}
+	}
+}
@@ -1,12 +1,23 @@
 package lox
 
 import (
+	"fmt"
 	"golox/lox/expr"
 	"golox/lox/stmt"
 	"golox/lox/tok"
+	"strings"
 )
 
-type Scope map[string]bool
+// binding records a variable declared in a scope: its slot in the scope's
+// environment, whether its initializer has been resolved yet, and its
+// symbol. The symbol is nil for this and super.
+type binding struct {
+	slot    int
+	defined bool
+	symbol  *Symbol
+}
+
+type Scope map[string]*binding
 
 type FunctionType int
 
@@ -26,25 +37,68 @@ const (
 )
 
 type Resolver struct {
+	lox             *Interpreter
 	scopes          []Scope
+	diagnostics     []*Diagnostic
 	currentFunction FunctionType
 	currentClass    ClassType
+	loopDepth       int
+	// lint enables warnings about code that is legal but probably wrong.
+	lint          bool
+	symbols       []*Symbol
+	globalSymbols map[string]*Symbol
+	// unresolved holds uses of names that aren't local. They refer to
+	// globals, which may be declared later in the file.
+	unresolved []Reference
+}
+
+// NewResolver returns a resolver that records the location of each local
+// variable in lox. If lox is nil, the resolver only checks the program and
+// collects its symbols.
+func NewResolver(lox *Interpreter) *Resolver {
+	return &Resolver{lox: lox, globalSymbols: make(map[string]*Symbol)}
 }
 
-func NewResolver() *Resolver {
-	return &Resolver{}
+func (r *Resolver) Diagnostics() []*Diagnostic {
+	return r.diagnostics
+}
+
+// Symbols returns the declarations the resolver has found, in order. Uses
+// of globals are matched to their declarations by name.
+func (r *Resolver) Symbols() []*Symbol {
+	var unresolved []Reference
+	for _, ref := range r.unresolved {
+		if symbol, ok := r.globalSymbols[ref.Token.Lexeme]; ok {
+			symbol.References = append(symbol.References, ref)
+		} else {
+			unresolved = append(unresolved, ref)
+		}
+	}
+	r.unresolved = unresolved
+	return r.symbols
 }
 
 func (r *Resolver) ResolveStatements(statements []stmt.Stmt) {
+	reachable := true
 	for _, st := range statements {
+		if !reachable && r.lint {
+			if t := stmt.FirstToken(st); t != nil {
+				r.warn(t, CodeUnreachableCode, "Unreachable code")
+			}
+			reachable = true
+		}
 		r.ResolveStatement(st)
+		switch st.(type) {
+		case *stmt.Return, *stmt.Throw, *stmt.Break, *stmt.Continue:
+			reachable = false
+		}
 	}
 }
 
 func (r *Resolver) ResolveStatement(st stmt.Stmt) {
 	switch s := st.(type) {
 	case *stmt.Function:
-		r.declare(s.Name)
+		r.declare(s.Name, SymbolFunction, s)
 		r.define(s.Name)
 		r.resolveFunction(s, FunctionTypeFunction)
 	case *stmt.Block:
@@ -52,7 +106,7 @@ func (r *Resolver) ResolveStatement(st stmt.Stmt) {
 		r.ResolveStatements(s.Statements)
 		r.endScope()
 	case *stmt.Var:
-		r.declare(s.Name)
+		r.declare(s.Name, SymbolVariable, s)
 		if s.Initializer != nil {
 			r.ResolveExpression(s.Initializer)
 		}
@@ -61,6 +115,9 @@ func (r *Resolver) ResolveStatement(st stmt.Stmt) {
 		r.ResolveExpression(s.Expression)
 	case *stmt.If:
 		r.ResolveExpression(s.Condition)
+		if r.lint {
+			r.checkCondition(s.Condition)
+		}
 		r.ResolveStatement(s.ThenBranch)
 		if s.ElseBranch != nil {
 			r.ResolveStatement(s.ElseBranch)
@@ -71,7 +128,45 @@ func (r *Resolver) ResolveStatement(st stmt.Stmt) {
 		r.returnStmt(s)
 	case *stmt.While:
 		r.ResolveExpression(s.Condition)
+		r.loopDepth++
+		r.ResolveStatement(s.Body)
+		r.loopDepth--
+		if s.Increment != nil {
+			r.ResolveExpression(s.Increment)
+		}
+	case *stmt.Import:
+		if len(r.scopes) > 0 {
+			r.error(s.Keyword, CodeNotTopLevel, "Can only import at the top level")
+		}
+		r.declare(s.Name, SymbolModule, s)
+		r.define(s.Name)
+	case *stmt.Export:
+		if len(r.scopes) > 0 {
+			r.error(s.Keyword, CodeNotTopLevel, "Can only export top-level declarations")
+		}
+		r.ResolveStatement(s.Declaration)
+	case *stmt.Throw:
+		r.ResolveExpression(s.Value)
+	case *stmt.Try:
 		r.ResolveStatement(s.Body)
+		if s.Catch != nil {
+			r.beginScope()
+			r.declare(s.CatchName, SymbolVariable, s)
+			r.define(s.CatchName)
+			r.ResolveStatements(s.Catch.Statements)
+			r.endScope()
+		}
+		if s.Finally != nil {
+			r.ResolveStatement(s.Finally)
+		}
+	case *stmt.Break:
+		if r.loopDepth == 0 {
+			r.error(s.Keyword, CodeBreakOutsideLoop, "Can't use 'break' outside a loop")
+		}
+	case *stmt.Continue:
+		if r.loopDepth == 0 {
+			r.error(s.Keyword, CodeContinueOutsideLoop, "Can't use 'continue' outside a loop")
+		}
 	case *stmt.Class:
 		r.classStmt(s)
 	}
@@ -79,18 +174,12 @@ func (r *Resolver) ResolveStatement(st stmt.Stmt) {
 
 func (r *Resolver) returnStmt(s *stmt.Return) {
 	if r.currentFunction == FunctionTypeNone {
-		ReportParseError(&Error{
-			Token:   s.Keyword,
-			Message: "Can't return from top-level code",
-		})
+		r.error(s.Keyword, CodeTopLevelReturn, "Can't return from top-level code")
 	}
 
 	if s.Value != nil {
 		if r.currentFunction == FunctionTypeInitializer {
-			ReportParseError(&Error{
-				Token:   s.Keyword,
-				Message: "Can't return a value from an initializer",
-			})
+			r.error(s.Keyword, CodeInitializerReturn, "Can't return a value from an initializer")
 		}
 		r.ResolveExpression(s.Value)
 	}
@@ -100,14 +189,11 @@ func (r *Resolver) classStmt(s *stmt.Class) {
 	enclosingClass := r.currentClass
 	r.currentClass = ClassTypeClass
 
-	r.declare(s.Name)
+	class := r.declare(s.Name, SymbolClass, s)
 	r.define(s.Name)
 
 	if s.Superclass != nil && s.Superclass.Name.Lexeme == s.Name.Lexeme {
-		ReportParseError(&Error{
-			Token:   s.Superclass.Name,
-			Message: "A class can't inherit from itself",
-		})
+		r.error(s.Superclass.Name, CodeSelfInheritance, "A class can't inherit from itself")
 	}
 
 	if s.Superclass != nil {
@@ -117,13 +203,19 @@ func (r *Resolver) classStmt(s *stmt.Class) {
 
 	if s.Superclass != nil {
 		r.beginScope()
-		r.peekScope()["super"] = true
+		r.peekScope()["super"] = &binding{slot: 0, defined: true}
 	}
 
 	r.beginScope()
-	r.peekScope()["this"] = true
+	r.peekScope()["this"] = &binding{slot: 0, defined: true}
 
 	for _, m := range s.Methods {
+		r.symbols = append(r.symbols, &Symbol{
+			Name:        m.Name,
+			Kind:        SymbolMethod,
+			Declaration: m,
+			Container:   class,
+		})
 		if m.Name.Lexeme == "init" {
 			r.resolveFunction(m, FunctionTypeInitializer)
 		} else {
@@ -145,7 +237,7 @@ func (r *Resolver) ResolveExpression(ex expr.Expr) {
 		r.variableExpr(e)
 	case *expr.Assign:
 		r.ResolveExpression(e.Value)
-		r.resolveLocal(e, e.Name)
+		r.resolveLocal(e, Reference{Token: e.Name, Assign: true})
 	case *expr.Binary:
 		r.ResolveExpression(e.Left)
 		r.ResolveExpression(e.Right)
@@ -167,6 +259,22 @@ func (r *Resolver) ResolveExpression(ex expr.Expr) {
 	case *expr.Set:
 		r.ResolveExpression(e.Object)
 		r.ResolveExpression(e.Value)
+	case *expr.List:
+		for _, element := range e.Elements {
+			r.ResolveExpression(element)
+		}
+	case *expr.Map:
+		for i := range e.Keys {
+			r.ResolveExpression(e.Keys[i])
+			r.ResolveExpression(e.Values[i])
+		}
+	case *expr.Index:
+		r.ResolveExpression(e.Object)
+		r.ResolveExpression(e.Index)
+	case *expr.SetIndex:
+		r.ResolveExpression(e.Object)
+		r.ResolveExpression(e.Index)
+		r.ResolveExpression(e.Value)
 	case *expr.This:
 		r.thisExpr(e)
 	case *expr.Super:
@@ -176,63 +284,63 @@ func (r *Resolver) ResolveExpression(ex expr.Expr) {
 
 func (r *Resolver) variableExpr(e *expr.Variable) {
 	if len(r.scopes) > 0 {
-		defined, declared := r.peekScope()[e.Name.Lexeme]
-		if declared && !defined {
-			ReportParseError(&Error{Token: e.Name, Message: "Can't read local variable in its own initializer"})
+		b, declared := r.peekScope()[e.Name.Lexeme]
+		if declared && !b.defined {
+			r.error(e.Name, CodeSelfInitializer, "Can't read local variable in its own initializer")
 		}
 	}
-	r.resolveLocal(e, e.Name)
+	r.resolveLocal(e, Reference{Token: e.Name})
 }
 
 func (r *Resolver) thisExpr(e *expr.This) {
 	if r.currentClass == ClassTypeNone {
-		ReportParseError(&Error{
-			Token:   e.Keyword,
-			Message: "Can't use 'this' outside a class",
-		})
+		r.error(e.Keyword, CodeThisOutsideClass, "Can't use 'this' outside a class")
 		return
 	}
-	r.resolveLocal(e, e.Keyword)
+	r.resolveLocal(e, Reference{Token: e.Keyword})
 }
 
 func (r *Resolver) superExpr(e *expr.Super) {
 	if r.currentClass == ClassTypeNone {
-		ReportParseError(&Error{
-			Token:   e.Keyword,
-			Message: "Can't use 'super' outside a class",
-		})
+		r.error(e.Keyword, CodeSuperOutsideClass, "Can't use 'super' outside a class")
 		return
 	} else if r.currentClass != ClassTypeSubclass {
-		ReportParseError(&Error{
-			Token:   e.Keyword,
-			Message: "Can't use 'super' in a class with no superclass",
-		})
+		r.error(e.Keyword, CodeSuperWithoutSuperclass, "Can't use 'super' in a class with no superclass")
 	}
 
-	r.resolveLocal(e, e.Keyword)
+	r.resolveLocal(e, Reference{Token: e.Keyword})
 }
 
-func (r *Resolver) resolveLocal(e expr.Expr, name *tok.Token) {
+func (r *Resolver) resolveLocal(e expr.Expr, ref Reference) {
 	for i := len(r.scopes) - 1; i >= 0; i-- {
-		_, declared := r.scopes[i][name.Lexeme]
+		b, declared := r.scopes[i][ref.Token.Lexeme]
 		if declared {
-			depthMap[e] = len(r.scopes) - i - 1
+			if r.lox != nil {
+				r.lox.resolve(e, len(r.scopes)-i-1, b.slot)
+			}
+			if b.symbol != nil {
+				b.symbol.References = append(b.symbol.References, ref)
+			}
 			return
 		}
 	}
+	r.unresolved = append(r.unresolved, ref)
 }
 
 func (r *Resolver) resolveFunction(s *stmt.Function, ft FunctionType) {
 	enclosingFunction := r.currentFunction
+	enclosingLoopDepth := r.loopDepth
 	r.currentFunction = ft
+	r.loopDepth = 0
 	r.beginScope()
 	for _, param := range s.Params {
-		r.declare(param)
+		r.declare(param, SymbolParameter, nil)
 		r.define(param)
 	}
 	r.ResolveStatements(s.Body)
 	r.endScope()
 	r.currentFunction = enclosingFunction
+	r.loopDepth = enclosingLoopDepth
 }
 
 func (r *Resolver) beginScope() {
@@ -240,6 +348,9 @@ func (r *Resolver) beginScope() {
 }
 
 func (r *Resolver) endScope() {
+	if r.lint {
+		r.checkUnused(r.peekScope())
+	}
 	r.scopes = r.scopes[:len(r.scopes)-1]
 }
 
@@ -247,20 +358,35 @@ func (r *Resolver) peekScope() Scope {
 	return r.scopes[len(r.scopes)-1]
 }
 
-func (r *Resolver) declare(name *tok.Token) {
+func (r *Resolver) declare(name *tok.Token, kind SymbolKind, declaration stmt.Stmt) *Symbol {
+	symbol := &Symbol{Name: name, Kind: kind, Declaration: declaration}
 	if len(r.scopes) == 0 {
-		return
+		// Globals can be redeclared. Treat later declarations as uses of
+		// the first.
+		if first, ok := r.globalSymbols[name.Lexeme]; ok {
+			first.References = append(first.References, Reference{Token: name, Assign: true})
+			return first
+		}
+		symbol.Global = true
+		r.globalSymbols[name.Lexeme] = symbol
+		r.symbols = append(r.symbols, symbol)
+		return symbol
 	}
 
 	scope := r.peekScope()
-	_, defined := scope[name.Lexeme]
-	if defined {
-		ReportParseError(&Error{
-			Token:   name,
-			Message: "Already a variable with this name in this scope"})
+	b, declared := scope[name.Lexeme]
+	if declared {
+		r.error(name, CodeRedeclaration, "Already a variable with this name in this scope")
+		b.defined = false
+		return b.symbol
+	}
+	if r.lint {
+		r.checkShadowing(name)
 	}
 
-	scope[name.Lexeme] = false
+	scope[name.Lexeme] = &binding{slot: len(scope), symbol: symbol}
+	r.symbols = append(r.symbols, symbol)
+	return symbol
 }
 
 func (r *Resolver) define(name *tok.Token) {
@@ -268,5 +394,88 @@ func (r *Resolver) define(name *tok.Token) {
 		return
 	}
 
-	r.peekScope()[name.Lexeme] = true
+	r.peekScope()[name.Lexeme].defined = true
+}
+
+func (r *Resolver) error(name *tok.Token, code Code, message string) {
+	err := &Error{Token: name, Code: code, Message: message}
+	r.diagnostics = append(r.diagnostics, newTokenDiagnostic(PhaseResolve, err))
+}
+
+func (r *Resolver) warn(name *tok.Token, code Code, message string) {
+	d := newTokenDiagnostic(PhaseResolve, &Error{Token: name, Code: code, Message: message})
+	d.Severity = SeverityWarning
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+// checkUnused warns about the variables in scope that are never read.
+// Names starting with an underscore are meant to be unused.
+func (r *Resolver) checkUnused(scope Scope) {
+	for name, b := range scope {
+		if b.symbol == nil || strings.HasPrefix(name, "_") {
+			continue
+		}
+		used := false
+		for _, ref := range b.symbol.References {
+			if !ref.Assign {
+				used = true
+				break
+			}
+		}
+		if used {
+			continue
+		}
+		if b.symbol.Kind == SymbolParameter {
+			r.warn(b.symbol.Name, CodeUnusedParameter,
+				fmt.Sprintf("Parameter '%s' is never used", name))
+		} else {
+			r.warn(b.symbol.Name, CodeUnusedVariable,
+				fmt.Sprintf("Local %s '%s' is never used", b.symbol.Kind, name))
+		}
+	}
+}
+
+// checkShadowing warns if a local declaration hides a variable from an
+// enclosing scope or a global declared earlier.
+func (r *Resolver) checkShadowing(name *tok.Token) {
+	var outer *Symbol
+	for i := len(r.scopes) - 2; i >= 0 && outer == nil; i-- {
+		if b, ok := r.scopes[i][name.Lexeme]; ok {
+			outer = b.symbol
+		}
+	}
+	if outer == nil {
+		outer = r.globalSymbols[name.Lexeme]
+	}
+	if outer != nil {
+		r.warn(name, CodeShadowedVariable, fmt.Sprintf(
+			"'%s' shadows the %s declared on line %d", name.Lexeme, outer.Kind, outer.Name.Line))
+	}
+}
+
+// checkCondition warns about an if statement whose condition is a literal.
+func (r *Resolver) checkCondition(condition expr.Expr) {
+	for {
+		grouping, ok := condition.(*expr.Grouping)
+		if !ok {
+			break
+		}
+		condition = grouping.Expression
+	}
+	if literal, ok := condition.(*expr.Literal); ok && literal.Token != nil {
+		r.warn(literal.Token, CodeConstantCondition,
+			fmt.Sprintf("Condition is always %t", isTruthy(literal.Value)))
+	}
+}
+
+// checkGlobals warns about assignments to globals that are never declared.
+// It must be called after the whole program has been resolved.
+func (r *Resolver) checkGlobals() {
+	r.Symbols()
+	for _, ref := range r.unresolved {
+		if ref.Assign {
+			r.warn(ref.Token, CodeUndeclaredGlobal,
+				fmt.Sprintf("Assignment to undeclared global '%s'", ref.Token.Lexeme))
+		}
+	}
 }
@@ -1,69 +1,185 @@
 package lox
 
 import (
-	"bufio"
 	"fmt"
 	"golox/lox/stmt"
 	"os"
+	"path/filepath"
 )
 
-func interpret(statements []stmt.Stmt) {
-	for _, s := range statements {
-		err := Exec(s)
-		if err != nil {
-			runtimeError, ok := err.(*Error)
-			if ok {
-				ReportRuntimeError(runtimeError)
-			} else {
-				fmt.Printf("Error: %s\n", err)
+func (in *Interpreter) interpret(file string, statements []stmt.Stmt) {
+	var err error
+	if in.backend == BackendVM {
+		err = in.interpretVM(file, statements)
+	} else {
+		for _, s := range statements {
+			if err = in.Exec(s); err != nil {
+				break
 			}
-			break
 		}
 	}
+
+	if err != nil {
+		runtimeError, ok := err.(*Error)
+		if ok {
+			in.reportRuntimeError(file, runtimeError)
+		} else {
+			fmt.Fprintf(in.stderr, "Error: %s\n", err)
+		}
+	}
+}
+
+func (in *Interpreter) interpretVM(file string, statements []stmt.Stmt) error {
+	p, ok := in.compile(statements)
+	if !ok {
+		return nil
+	}
+	return in.vm.interpret(p)
+}
+
+// compile compiles statements for the VM, reporting any compile error.
+func (in *Interpreter) compile(statements []stmt.Stmt) (*proto, bool) {
+	p, compileError := compile(statements)
+	if compileError != nil {
+		in.report(newTokenDiagnostic(PhaseCompile, compileError))
+		return nil, false
+	}
+	return p, true
+}
+
+// Check scans, parses and resolves source, returning the resulting
+// statements along with any diagnostics. The statements should only be
+// executed if none of the diagnostics are errors.
+func (in *Interpreter) Check(file string, source string) ([]stmt.Stmt, []*Diagnostic) {
+	statements, diagnostics := parse(file, source)
+	if len(diagnostics) == 0 {
+		resolver := NewResolver(in)
+		resolver.ResolveStatements(statements)
+		diagnostics = append(diagnostics, resolver.Diagnostics()...)
+		for _, d := range diagnostics {
+			d.Span.File = file
+		}
+	}
+	return statements, diagnostics
 }
 
-func run(source string) {
+// parse scans and parses source without resolving it.
+func parse(file string, source string) ([]stmt.Stmt, []*Diagnostic) {
 	scanner := NewScanner(source)
 	tokens := scanner.ScanTokens()
+	for _, t := range tokens {
+		t.File = file
+	}
+	diagnostics := scanner.Diagnostics()
 
 	parser := NewParser(tokens)
 	statements := parser.Parse()
-	if HadError {
-		return
+	diagnostics = append(diagnostics, parser.Diagnostics()...)
+
+	for _, d := range diagnostics {
+		d.Span.File = file
 	}
+	return statements, diagnostics
+}
 
-	resolver := NewResolver()
-	resolver.ResolveStatements(statements)
-	if HadError {
+func (in *Interpreter) run(file string, source string) {
+	in.sources[file] = source
+	in.main.name = file
+	in.main.path = file
+	statements, diagnostics := in.Check(file, source)
+	for _, d := range diagnostics {
+		in.report(d)
+	}
+	if in.hadError {
 		return
 	}
 
-	interpret(statements)
+	// The REPL echoes the value of a line that is a single expression.
+	if file == "" && len(statements) == 1 {
+		if e, ok := statements[0].(*stmt.Expression); ok {
+			statements[0] = &stmt.Print{Expression: e.Expression}
+		}
+	}
+
+	// The script is the main module, so relative imports are resolved
+	// from its directory.
+	if file != "" {
+		in.modules[filepath.Clean(file)] = in.main
+	}
+	in.importing = append(in.importing, in.main)
+	in.interpret(file, statements)
+	in.importing = in.importing[:len(in.importing)-1]
+}
+
+// RunFile runs the script at path, and exits with the status given by
+// ExitCode if the script has errors.
+func (in *Interpreter) RunFile(path string) error {
+	if err := in.Run(path); err != nil {
+		return err
+	}
+	if status := in.ExitCode(); status != 0 {
+		os.Exit(status)
+	}
+	return nil
+}
+
+// Run runs the script at path, reporting any errors in it as diagnostics.
+// It only returns an error if the script can't be read.
+func (in *Interpreter) Run(path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	in.run(path, string(bytes))
+	return nil
+}
+
+// ExitCode returns the conventional exit status for the scripts run so
+// far: 65 if one had a compile error, 70 if one had a runtime error, and
+// 0 otherwise.
+func (in *Interpreter) ExitCode() int {
+	if in.hadError {
+		return 65
+	}
+	if in.hadRuntimeError {
+		return 70
+	}
+	return 0
 }
 
-func RunFile(path string) error {
+// DumpAST parses the script at path and prints its syntax tree as
+// S-expressions, one top-level statement at a time. Like Run, it reports
+// syntax errors as diagnostics, in which case nothing is printed and
+// ExitCode returns 65. It only returns an error if the script can't be
+// read.
+func (in *Interpreter) DumpAST(path string) error {
 	bytes, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	run(string(bytes))
-	if HadError {
-		os.Exit(65)
+	in.sources[path] = string(bytes)
+	in.main.path = path
+	statements, diagnostics := parse(path, string(bytes))
+	for _, d := range diagnostics {
+		in.report(d)
 	}
-	if HadRuntimeError {
-		os.Exit(70)
+	if in.hadError {
+		return nil
+	}
+	for _, s := range statements {
+		fmt.Fprintln(in.stdout, stmt.PrintStmt(s))
 	}
 	return nil
 }
 
-func RunPrompt() {
-	scanner := bufio.NewScanner(os.Stdin)
+func (in *Interpreter) RunPrompt() {
 	for {
-		fmt.Printf("> ")
-		if !scanner.Scan() {
+		fmt.Fprintf(in.stdout, "> ")
+		line, err := readLine(in.stdin)
+		if line == nil || err != nil {
 			break
 		}
-		run(scanner.Text())
-		HadError = false
+		in.run("", line.(string))
+		in.hadError = false
 	}
 }
@@ -7,30 +7,44 @@ import (
 )
 
 var identifierMap = map[string]tok.Type{
-	"and":    tok.And,
-	"class":  tok.Class,
-	"else":   tok.Else,
-	"false":  tok.False,
-	"for":    tok.For,
-	"fun":    tok.Fun,
-	"if":     tok.If,
-	"nil":    tok.Nil,
-	"or":     tok.Or,
-	"print":  tok.Print,
-	"return": tok.Return,
-	"super":  tok.Super,
-	"this":   tok.This,
-	"true":   tok.True,
-	"var":    tok.Var,
-	"while":  tok.While,
+	"and":      tok.And,
+	"as":       tok.As,
+	"break":    tok.Break,
+	"catch":    tok.Catch,
+	"class":    tok.Class,
+	"continue": tok.Continue,
+	"else":     tok.Else,
+	"export":   tok.Export,
+	"false":    tok.False,
+	"finally":  tok.Finally,
+	"for":      tok.For,
+	"fun":      tok.Fun,
+	"if":       tok.If,
+	"import":   tok.Import,
+	"nil":      tok.Nil,
+	"or":       tok.Or,
+	"print":    tok.Print,
+	"return":   tok.Return,
+	"super":    tok.Super,
+	"this":     tok.This,
+	"throw":    tok.Throw,
+	"true":     tok.True,
+	"try":      tok.Try,
+	"var":      tok.Var,
+	"while":    tok.While,
 }
 
 type Scanner struct {
-	source  string
-	tokens  []*tok.Token
-	start   int
-	current int
-	line    int
+	source      string
+	tokens      []*tok.Token
+	comments    []*tok.Token
+	diagnostics []*Diagnostic
+	start       int
+	current     int
+	line        int
+	lineStart   int
+	startLine   int
+	startColumn int
 }
 
 func NewScanner(source string) *Scanner {
@@ -42,14 +56,25 @@ func NewScanner(source string) *Scanner {
 
 func (s *Scanner) ScanTokens() []*tok.Token {
 	for !s.isAtEnd() {
-		s.start = s.current
+		s.markStart()
 		s.scanToken()
 	}
 
-	s.tokens = append(s.tokens, tok.NewToken(tok.EOF, "", nil, s.line))
+	s.markStart()
+	s.addToken(tok.EOF)
 	return s.tokens
 }
 
+// Comments returns the comments in the source, in order. They are not
+// included in the tokens returned by ScanTokens.
+func (s *Scanner) Comments() []*tok.Token {
+	return s.comments
+}
+
+func (s *Scanner) Diagnostics() []*Diagnostic {
+	return s.diagnostics
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
@@ -65,6 +90,12 @@ func (s *Scanner) scanToken() {
 		s.addToken(tok.LeftBrace)
 	case '}':
 		s.addToken(tok.RightBrace)
+	case '[':
+		s.addToken(tok.LeftBracket)
+	case ']':
+		s.addToken(tok.RightBracket)
+	case ':':
+		s.addToken(tok.Colon)
 	case ',':
 		s.addToken(tok.Comma)
 	case '.':
@@ -108,6 +139,7 @@ func (s *Scanner) scanToken() {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			s.comments = append(s.comments, s.makeToken(tok.Comment, nil))
 		} else {
 			s.addToken(tok.Slash)
 		}
@@ -115,7 +147,7 @@ func (s *Scanner) scanToken() {
 		// Ignore whitespace
 		break
 	case '\n':
-		s.line++
+		s.newline()
 	case '"':
 		s.string()
 	default:
@@ -124,7 +156,7 @@ func (s *Scanner) scanToken() {
 		} else if isAlpha(c) {
 			s.identifier()
 		} else {
-			ReportScanError(s.line, "Unexpected character.")
+			s.error(CodeUnexpectedCharacter, "Unexpected character.")
 		}
 	}
 }
@@ -162,14 +194,14 @@ func (s *Scanner) number() {
 
 func (s *Scanner) string() {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
-		}
 		s.advance()
+		if s.previous() == '\n' {
+			s.newline()
+		}
 	}
 
 	if s.isAtEnd() {
-		ReportScanError(s.line, "Unterminated string.")
+		s.error(CodeUnterminatedString, "Unterminated string.")
 		return
 	}
 
@@ -181,6 +213,21 @@ func (s *Scanner) string() {
 	s.addLiteralToken(tok.String, value)
 }
 
+func (s *Scanner) error(code Code, message string) {
+	s.diagnostics = append(s.diagnostics, &Diagnostic{
+		Severity: SeverityError,
+		Phase:    PhaseScan,
+		Code:     code,
+		Message:  message,
+		Span: Span{
+			Line:   s.startLine,
+			Column: s.startColumn,
+			Offset: s.start,
+			Length: s.current - s.start,
+		},
+	})
+}
+
 func (s *Scanner) advance() rune {
 	c := s.source[s.current]
 	s.current++
@@ -192,8 +239,32 @@ func (s *Scanner) addToken(tokenType tok.Type) {
 }
 
 func (s *Scanner) addLiteralToken(tokenType tok.Type, literal any) {
+	s.tokens = append(s.tokens, s.makeToken(tokenType, literal))
+}
+
+func (s *Scanner) makeToken(tokenType tok.Type, literal any) *tok.Token {
 	text := s.source[s.start:s.current]
-	s.tokens = append(s.tokens, tok.NewToken(tokenType, text, literal, s.line))
+	t := tok.NewToken(tokenType, text, literal, s.startLine)
+	t.Column = s.startColumn
+	t.Offset = s.start
+	t.EndLine = s.line
+	t.EndColumn = s.column()
+	return t
+}
+
+func (s *Scanner) markStart() {
+	s.start = s.current
+	s.startLine = s.line
+	s.startColumn = s.column()
+}
+
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+}
+
+func (s *Scanner) column() int {
+	return s.current - s.lineStart + 1
 }
 
 func (s *Scanner) match(expected rune) bool {
@@ -215,6 +286,10 @@ func (s *Scanner) peek() rune {
 	}
 }
 
+func (s *Scanner) previous() rune {
+	return rune(s.source[s.current-1])
+}
+
 func (s *Scanner) peekNext() rune {
 	if s.current+1 >= len(s.source) {
 		return 0
@@ -0,0 +1,77 @@
+package lox
+
+import (
+	"golox/lox/tok"
+	"testing"
+)
+
+func TestTokenPositions(t *testing.T) {
+	source := "var x = 1;\n\tprint \"a\nb\" + \"é\";\n"
+	want := []struct {
+		typ                  tok.Type
+		lexeme               string
+		line, column, offset int
+		endLine, endColumn   int
+	}{
+		{tok.Var, "var", 1, 1, 0, 1, 4},
+		{tok.Identifier, "x", 1, 5, 4, 1, 6},
+		{tok.Equal, "=", 1, 7, 6, 1, 8},
+		{tok.Number, "1", 1, 9, 8, 1, 10},
+		{tok.Semicolon, ";", 1, 10, 9, 1, 11},
+		{tok.Print, "print", 2, 2, 12, 2, 7},
+		{tok.String, "\"a\nb\"", 2, 8, 18, 3, 3},
+		{tok.Plus, "+", 3, 4, 24, 3, 5},
+		// Columns count bytes, so the two-byte é widens the string.
+		{tok.String, "\"é\"", 3, 6, 26, 3, 10},
+		{tok.Semicolon, ";", 3, 10, 30, 3, 11},
+		{tok.EOF, "", 4, 1, 32, 4, 1},
+	}
+
+	scanner := NewScanner(source)
+	tokens := scanner.ScanTokens()
+	if len(scanner.Diagnostics()) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", scanner.Diagnostics())
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d: %v", len(tokens), len(want), tokens)
+	}
+	for i, w := range want {
+		got := tokens[i]
+		if got.Type != w.typ || got.Lexeme != w.lexeme || got.Line != w.line ||
+			got.Column != w.column || got.Offset != w.offset ||
+			got.EndLine != w.endLine || got.EndColumn != w.endColumn {
+			t.Errorf("token %d = %s %q at %d:%d+%d to %d:%d, want %s %q at %d:%d+%d to %d:%d",
+				i, got.Type, got.Lexeme, got.Line, got.Column, got.Offset, got.EndLine, got.EndColumn,
+				w.typ, w.lexeme, w.line, w.column, w.offset, w.endLine, w.endColumn)
+		}
+		if source[got.Offset:got.Offset+len(got.Lexeme)] != got.Lexeme {
+			t.Errorf("token %d: offset %d doesn't point at %q", i, got.Offset, got.Lexeme)
+		}
+	}
+}
+
+func TestScannerRecordsComments(t *testing.T) {
+	scanner := NewScanner("// first\nvar x; // second\n")
+	scanner.ScanTokens()
+	comments := scanner.Comments()
+	if len(comments) != 2 {
+		t.Fatalf("got %d comments, want 2", len(comments))
+	}
+	if comments[0].Lexeme != "// first" || comments[0].Line != 1 ||
+		comments[1].Lexeme != "// second" || comments[1].Line != 2 || comments[1].Column != 8 {
+		t.Errorf("got comments %q at %d:%d and %q at %d:%d", comments[0].Lexeme, comments[0].Line,
+			comments[0].Column, comments[1].Lexeme, comments[1].Line, comments[1].Column)
+	}
+}
+
+func TestScanErrorPosition(t *testing.T) {
+	scanner := NewScanner("var s = \"open\n")
+	scanner.ScanTokens()
+	diagnostics := scanner.Diagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].Code != CodeUnterminatedString {
+		t.Fatalf("got %v, want an unterminated string", diagnostics)
+	}
+	if span := diagnostics[0].Span; span.Line != 1 || span.Column != 9 || span.Offset != 8 {
+		t.Errorf("span = %+v, want line 1, column 9, offset 8", span)
+	}
+}
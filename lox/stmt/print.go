@@ -0,0 +1,137 @@
+package stmt
+
+import (
+	"fmt"
+	"golox/lox/expr"
+	"strings"
+)
+
+// PrintStmt formats a statement as an S-expression, for debugging the
+// parser. Nested statements are written on their own lines, indented
+// under the statement that contains them.
+func PrintStmt(stmt Stmt) string {
+	p := &printer{}
+	p.stmt(stmt)
+	return p.sb.String()
+}
+
+type printer struct {
+	sb     strings.Builder
+	indent int
+}
+
+func (p *printer) stmt(stmt Stmt) {
+	switch s := stmt.(type) {
+	case *Expression:
+		p.printf("(; %s)", expr.PrintExpr(s.Expression))
+	case *Print:
+		p.printf("(print %s)", expr.PrintExpr(s.Expression))
+	case *Var:
+		if s.Initializer == nil {
+			p.printf("(var %s)", s.Name.Lexeme)
+		} else {
+			p.printf("(var %s = %s)", s.Name.Lexeme, expr.PrintExpr(s.Initializer))
+		}
+	case *Block:
+		p.printf("(block")
+		p.nested(s.Statements...)
+		p.printf(")")
+	case *If:
+		if s.ElseBranch == nil {
+			p.printf("(if %s", expr.PrintExpr(s.Condition))
+			p.nested(s.ThenBranch)
+		} else {
+			p.printf("(if-else %s", expr.PrintExpr(s.Condition))
+			p.nested(s.ThenBranch, s.ElseBranch)
+		}
+		p.printf(")")
+	case *While:
+		p.printf("(while %s", expr.PrintExpr(s.Condition))
+		if s.Increment != nil {
+			p.printf(" (increment %s)", expr.PrintExpr(s.Increment))
+		}
+		p.nested(s.Body)
+		p.printf(")")
+	case *Function:
+		p.function("fun", s)
+	case *Return:
+		if s.Value == nil {
+			p.printf("(return)")
+		} else {
+			p.printf("(return %s)", expr.PrintExpr(s.Value))
+		}
+	case *Class:
+		p.printf("(class %s", s.Name.Lexeme)
+		if s.Superclass != nil {
+			p.printf(" < %s", s.Superclass.Name.Lexeme)
+		}
+		p.indent++
+		for _, method := range s.Methods {
+			p.newline()
+			p.function("method", method)
+		}
+		p.indent--
+		p.printf(")")
+	case *Break:
+		p.printf("(break)")
+	case *Continue:
+		p.printf("(continue)")
+	case *Throw:
+		p.printf("(throw %s)", expr.PrintExpr(s.Value))
+	case *Try:
+		p.printf("(try")
+		p.nested(s.Body)
+		p.indent++
+		if s.Catch != nil {
+			p.newline()
+			p.printf("(catch %s", s.CatchName.Lexeme)
+			p.nested(s.Catch.Statements...)
+			p.printf(")")
+		}
+		if s.Finally != nil {
+			p.newline()
+			p.printf("(finally")
+			p.nested(s.Finally.Statements...)
+			p.printf(")")
+		}
+		p.indent--
+		p.printf(")")
+	case *Import:
+		p.printf("(import %s as %s)", s.Path.Lexeme, s.Name.Lexeme)
+	case *Export:
+		p.printf("(export")
+		p.nested(s.Declaration)
+		p.printf(")")
+	default:
+		p.printf("(unknown %T)", stmt)
+	}
+}
+
+func (p *printer) function(kind string, f *Function) {
+	params := make([]string, len(f.Params))
+	for i, param := range f.Params {
+		params[i] = param.Lexeme
+	}
+	p.printf("(%s %s(%s)", kind, f.Name.Lexeme, strings.Join(params, " "))
+	p.nested(f.Body...)
+	p.printf(")")
+}
+
+// nested writes statements on their own lines, one level further in.
+func (p *printer) nested(stmts ...Stmt) {
+	p.indent++
+	for _, s := range stmts {
+		p.newline()
+		p.stmt(s)
+	}
+	p.indent--
+}
+
+func (p *printer) newline() {
+	p.sb.WriteRune('\n')
+	p.sb.WriteString(strings.Repeat("  ", p.indent))
+}
+
+func (p *printer) printf(format string, args ...any) {
+	fmt.Fprintf(&p.sb, format, args...)
+}
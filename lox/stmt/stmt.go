@@ -18,12 +18,19 @@ func (s *While) stmt()      {}
 func (e *Function) stmt()   {}
 func (e *Return) stmt()     {}
 func (e *Class) stmt()      {}
+func (s *Break) stmt()      {}
+func (s *Continue) stmt()   {}
+func (s *Throw) stmt()      {}
+func (s *Try) stmt()        {}
+func (s *Import) stmt()     {}
+func (s *Export) stmt()     {}
 
 type Expression struct {
 	Expression expr.Expr
 }
 
 type Print struct {
+	Keyword    *tok.Token
 	Expression expr.Expr
 }
 
@@ -32,19 +39,28 @@ type Var struct {
 	Initializer expr.Expr
 }
 
+// Block is a block statement. Brace is nil for the block that holds a for
+// loop's initializer.
 type Block struct {
+	Brace      *tok.Token
 	Statements []Stmt
 }
 
 type If struct {
+	Keyword    *tok.Token
 	Condition  expr.Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
 }
 
+// While is a while loop, or a desugared for loop. Keyword is the while or
+// for keyword. Increment is the for loop's increment clause, which runs
+// after the body and after a continue.
 type While struct {
+	Keyword   *tok.Token
 	Condition expr.Expr
 	Body      Stmt
+	Increment expr.Expr
 }
 
 type Function struct {
@@ -63,3 +79,87 @@ type Class struct {
 	Superclass *expr.Variable
 	Methods    []*Function
 }
+
+type Break struct {
+	Keyword *tok.Token
+}
+
+type Continue struct {
+	Keyword *tok.Token
+}
+
+type Throw struct {
+	Keyword *tok.Token
+	Value   expr.Expr
+}
+
+// Import is an import statement: import "path" as name;
+type Import struct {
+	Keyword *tok.Token
+	Path    *tok.Token
+	Name    *tok.Token
+}
+
+// Export marks a top-level class, function or variable declaration as
+// visible to modules that import this one.
+type Export struct {
+	Keyword     *tok.Token
+	Declaration Stmt
+}
+
+// Try is a try statement. CatchName and Catch are nil if there is no catch
+// clause, and Finally is nil if there is no finally clause. The caught
+// value is bound to CatchName in the same scope as the statements of the
+// catch block.
+type Try struct {
+	Keyword   *tok.Token
+	Body      *Block
+	CatchName *tok.Token
+	Catch     *Block
+	Finally   *Block
+}
+
+// FirstToken returns a token at the start of a statement, for reporting
+// its position. For declarations it is the name being declared rather than
+// the keyword. It returns nil if the statement has no tokens.
+func FirstToken(stmt Stmt) *tok.Token {
+	switch s := stmt.(type) {
+	case *Expression:
+		return expr.FirstToken(s.Expression)
+	case *Print:
+		if s.Keyword == nil {
+			return expr.FirstToken(s.Expression)
+		}
+		return s.Keyword
+	case *Var:
+		return s.Name
+	case *Block:
+		if s.Brace == nil && len(s.Statements) > 0 {
+			return FirstToken(s.Statements[0])
+		}
+		return s.Brace
+	case *If:
+		return s.Keyword
+	case *While:
+		return s.Keyword
+	case *Function:
+		return s.Name
+	case *Return:
+		return s.Keyword
+	case *Class:
+		return s.Name
+	case *Break:
+		return s.Keyword
+	case *Continue:
+		return s.Keyword
+	case *Throw:
+		return s.Keyword
+	case *Try:
+		return s.Keyword
+	case *Import:
+		return s.Keyword
+	case *Export:
+		return s.Keyword
+	}
+	return nil
+}
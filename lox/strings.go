@@ -0,0 +1,133 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringProperty returns the method called name on the string s. Strings
+// are indexed by Unicode code point rather than by byte.
+func stringProperty(s string, name string) (any, error) {
+	switch name {
+	case "len":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return float64(utf8.RuneCountInString(s)), nil
+		}), nil
+	case "substring":
+		return NewNativeFunction(name, 2, func(args []any) (any, error) {
+			runes := []rune(s)
+			start, err := checkIndex("String", args[0], len(runes)+1)
+			if err != nil {
+				return nil, err
+			}
+			end, err := checkIndex("String", args[1], len(runes)+1)
+			if err != nil {
+				return nil, err
+			}
+			if end < start {
+				return nil, fmt.Errorf("Substring end is before start")
+			}
+			return string(runes[start:end]), nil
+		}), nil
+	case "indexOf":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			sub, err := stringArgument(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+			i := strings.Index(s, sub)
+			if i < 0 {
+				return float64(-1), nil
+			}
+			return float64(utf8.RuneCountInString(s[:i])), nil
+		}), nil
+	case "split":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			sep, err := stringArgument(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+			var elements []any
+			for _, part := range strings.Split(s, sep) {
+				elements = append(elements, part)
+			}
+			return NewList(elements), nil
+		}), nil
+	case "join":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			list, ok := args[0].(*List)
+			if !ok {
+				return nil, fmt.Errorf("join expects a list but got %s", typeName(args[0]))
+			}
+			parts := make([]string, len(list.elements))
+			for i, e := range list.elements {
+				part, ok := e.(string)
+				if !ok {
+					return nil, fmt.Errorf("join expects a list of strings but element %d is a %s", i, typeName(e))
+				}
+				parts[i] = part
+			}
+			return strings.Join(parts, s), nil
+		}), nil
+	case "trim":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return strings.TrimSpace(s), nil
+		}), nil
+	case "upper":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return strings.ToUpper(s), nil
+		}), nil
+	case "lower":
+		return NewNativeFunction(name, 0, func(args []any) (any, error) {
+			return strings.ToLower(s), nil
+		}), nil
+	case "replace":
+		return NewNativeFunction(name, 2, func(args []any) (any, error) {
+			old, err := stringArgument(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+			new, err := stringArgument(name, args[1])
+			if err != nil {
+				return nil, err
+			}
+			return strings.ReplaceAll(s, old, new), nil
+		}), nil
+	case "startsWith":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			prefix, err := stringArgument(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return strings.HasPrefix(s, prefix), nil
+		}), nil
+	case "codepoint":
+		return NewNativeFunction(name, 1, func(args []any) (any, error) {
+			runes := []rune(s)
+			i, err := checkIndex("String", args[0], len(runes))
+			if err != nil {
+				return nil, err
+			}
+			return float64(runes[i]), nil
+		}), nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+func stringArgument(method string, arg any) (string, error) {
+	s, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("%s expects a string but got %s", method, typeName(arg))
+	}
+	return s, nil
+}
+
+// char returns the string containing the single code point n.
+func char(args []any) (any, error) {
+	n, ok := args[0].(float64)
+	if !ok || n != float64(int32(n)) || !utf8.ValidRune(rune(n)) {
+		return nil, fmt.Errorf("char expects a valid code point but got %s", Repr(args[0]))
+	}
+	return string(rune(n)), nil
+}
@@ -0,0 +1,49 @@
+package lox
+
+import "testing"
+
+func TestStringMethods(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print "  Hello  ".len();`, "9\n"},
+		{`print "  Hello  ".trim();`, "Hello\n"},
+		{`print "Hello".upper(); print "Hello".lower();`, "HELLO\nhello\n"},
+		{`print "Hello".substring(1, 3); print "Hello".substring(5, 5) == "";`, "el\ntrue\n"},
+		{`print "Hello, World".indexOf("World"); print "Hello".indexOf("nope");`, "7\n-1\n"},
+		{`print "a,b,,c".split(","); print "abc".split("");`, `["a", "b", "", "c"]` + "\n" + `["a", "b", "c"]` + "\n"},
+		{`print "-".join(["a", "b", "c"]); print "".join([]) == "";`, "a-b-c\ntrue\n"},
+		{`print "hello".replace("l", "L");`, "heLLo\n"},
+		{`print "Hello".startsWith("He"); print "Hello".startsWith("lo");`, "true\nfalse\n"},
+		{`print "A".codepoint(0);`, "65\n"},
+		{`var upper = "x".upper; print upper();`, "X\n"},
+		// Strings are indexed by code point.
+		{`print "héllo".len(); print "héllo".indexOf("l"); print "héllo".substring(1, 3); print "é".codepoint(0);`,
+			"5\n2\nél\n233\n"},
+	}
+	for _, test := range tests {
+		expectOutput(t, test.source, test.want)
+	}
+}
+
+func TestStringMethodErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`"abc".substring(2, 1);`, "Substring end is before start"},
+		{`"abc".substring(0, 4);`, "String index 4 out of bounds"},
+		{`"abc".substring(0.5, 1);`, "String index must be an integer but got 0.5"},
+		{`"abc".codepoint(3);`, "String index 3 out of bounds"},
+		{`"abc".indexOf(1);`, "indexOf expects a string but got number"},
+		{`"abc".replace("a", nil);`, "replace expects a string but got nil"},
+		{`",".join("ab");`, "join expects a list but got string"},
+		{`",".join(["a", 1]);`, "join expects a list of strings but element 1 is a number"},
+		{`"abc".missing();`, "Undefined property 'missing'"},
+		{`"abc".len(1);`, "Expected 0 arguments but got 1"},
+	}
+	for _, test := range tests {
+		expectError(t, test.source, test.want)
+	}
+}
@@ -0,0 +1,83 @@
+package lox
+
+import (
+	"golox/lox/stmt"
+	"golox/lox/tok"
+)
+
+type SymbolKind int
+
+const (
+	SymbolVariable SymbolKind = iota
+	SymbolParameter
+	SymbolFunction
+	SymbolClass
+	SymbolMethod
+	SymbolModule
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolVariable:
+		return "variable"
+	case SymbolParameter:
+		return "parameter"
+	case SymbolFunction:
+		return "function"
+	case SymbolClass:
+		return "class"
+	case SymbolMethod:
+		return "method"
+	case SymbolModule:
+		return "module"
+	default:
+		return "???"
+	}
+}
+
+// Symbol is a declaration found by the resolver, along with the places
+// where it is used.
+type Symbol struct {
+	Name *tok.Token
+	Kind SymbolKind
+	// Global is true for top-level declarations.
+	Global bool
+	// Declaration is the statement that declares the symbol. It is nil
+	// for parameters.
+	Declaration stmt.Stmt
+	// Container is the class that declares a method.
+	Container  *Symbol
+	References []Reference
+}
+
+// Reference is a use of a symbol. Assign is true if the use assigns to it
+// rather than reading it.
+type Reference struct {
+	Token  *tok.Token
+	Assign bool
+}
+
+// Analysis is what tools need to know about a source file without
+// running it.
+type Analysis struct {
+	Statements  []stmt.Stmt
+	Diagnostics []*Diagnostic
+	Symbols     []*Symbol
+}
+
+// Analyze scans, parses and resolves source. Like Check, it only resolves
+// source that parses, so Symbols is empty if there are syntax errors.
+func Analyze(file string, source string) *Analysis {
+	statements, diagnostics := parse(file, source)
+	a := &Analysis{Statements: statements, Diagnostics: diagnostics}
+	if len(diagnostics) == 0 {
+		resolver := NewResolver(nil)
+		resolver.ResolveStatements(statements)
+		for _, d := range resolver.Diagnostics() {
+			d.Span.File = file
+			a.Diagnostics = append(a.Diagnostics, d)
+		}
+		a.Symbols = resolver.Symbols()
+	}
+	return a
+}
@@ -6,7 +6,17 @@ type Token struct {
 	Type    Type
 	Lexeme  string
 	Literal any
-	Line    int
+	// File is the name of the source file the token was scanned from.
+	File string
+	Line int
+	// Column is the 1-based byte column of the first character of the token,
+	// and Offset is its byte offset from the start of the source.
+	Column int
+	Offset int
+	// EndLine and EndColumn give the position just past the last character
+	// of the token.
+	EndLine   int
+	EndColumn int
 }
 
 func NewToken(t Type, lexeme string, literal any, line int) *Token {
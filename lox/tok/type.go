@@ -11,6 +11,9 @@ const (
 	RightParen
 	LeftBrace
 	RightBrace
+	LeftBracket
+	RightBracket
+	Colon
 	Comma
 	Dot
 	Minus
@@ -37,22 +40,36 @@ const (
 	String
 	Number
 
+	// Comments are not passed to the parser, but are kept for tools such
+	// as the formatter.
+
+	Comment
+
 	// Keywords
 
 	And
+	Break
+	Catch
 	Class
+	Continue
 	Else
+	Export
 	False
+	Finally
 	Fun
 	For
 	If
+	Import
 	Nil
 	Or
+	As
 	Print
 	Return
 	Super
 	This
+	Throw
 	True
+	Try
 	Var
 	While
 )
@@ -69,6 +86,12 @@ func (t Type) String() string {
 		return "LEFT_BRACE"
 	case RightBrace:
 		return "RIGHT_BRACE"
+	case LeftBracket:
+		return "LEFT_BRACKET"
+	case RightBracket:
+		return "RIGHT_BRACKET"
+	case Colon:
+		return "COLON"
 	case Comma:
 		return "COMMA"
 	case Dot:
@@ -107,24 +130,40 @@ func (t Type) String() string {
 		return "STRING"
 	case Number:
 		return "NUMBER"
+	case Comment:
+		return "COMMENT"
 	case And:
 		return "AND"
+	case Break:
+		return "BREAK"
+	case Catch:
+		return "CATCH"
 	case Class:
 		return "CLASS"
+	case Continue:
+		return "CONTINUE"
 	case Else:
 		return "ELSE"
+	case Export:
+		return "EXPORT"
 	case False:
 		return "FALSE"
+	case Finally:
+		return "FINALLY"
 	case Fun:
 		return "FUN"
 	case For:
 		return "FOR"
 	case If:
 		return "IF"
+	case Import:
+		return "IMPORT"
 	case Nil:
 		return "NIL"
 	case Or:
 		return "OR"
+	case As:
+		return "AS"
 	case Print:
 		return "PRINT"
 	case Return:
@@ -133,8 +172,12 @@ func (t Type) String() string {
 		return "SUPER"
 	case This:
 		return "THIS"
+	case Throw:
+		return "THROW"
 	case True:
 		return "TRUE"
+	case Try:
+		return "TRY"
 	case Var:
 		return "VAR"
 	case While:
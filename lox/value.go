@@ -0,0 +1,107 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// stringify formats a value the way print shows it. Strings are shown
+// without quotes, and numbers with formatNumber.
+func stringify(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return v
+	case float64:
+		return formatNumber(v)
+	case *List, *Map:
+		return repr(v, map[any]bool{})
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatNumber formats n the same way on every platform. Integers are
+// shown without a fractional part, and numbers are written out in full
+// unless they are very large or very small, when an exponent is used.
+func formatNumber(n float64) string {
+	switch {
+	case math.IsNaN(n):
+		return "NaN"
+	case math.IsInf(n, 1):
+		return "Infinity"
+	case math.IsInf(n, -1):
+		return "-Infinity"
+	}
+	if abs := math.Abs(n); abs != 0 && (abs < 1e-7 || abs >= 1e21) {
+		return strconv.FormatFloat(n, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+var numberPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// parseNumber parses a decimal number, ignoring surrounding whitespace.
+// Numbers too large to represent become infinity.
+func parseNumber(s string) (float64, error) {
+	text := strings.TrimSpace(s)
+	if !numberPattern.MatchString(text) {
+		return 0, fmt.Errorf("Can't convert %s to a number", strconv.Quote(s))
+	}
+	n, _ := strconv.ParseFloat(text, 64)
+	return n, nil
+}
+
+// repr formats a value inside a list or map, quoting strings so that they
+// can be told apart from other values. Collections that contain themselves
+// are shown as "[...]" or "{...}".
+func repr(value any, seen map[any]bool) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case *List:
+		if seen[v] {
+			return "[...]"
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		sb := &strings.Builder{}
+		sb.WriteRune('[')
+		for i, e := range v.elements {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(repr(e, seen))
+		}
+		sb.WriteRune(']')
+		return sb.String()
+	case *Map:
+		if seen[v] {
+			return "{...}"
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		sb := &strings.Builder{}
+		sb.WriteRune('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(repr(k, seen))
+			sb.WriteString(": ")
+			sb.WriteString(repr(v.values[i], seen))
+		}
+		sb.WriteRune('}')
+		return sb.String()
+	default:
+		return stringify(v)
+	}
+}
@@ -0,0 +1,56 @@
+package lox
+
+import "testing"
+
+func TestStringify(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print nil; print true; print false;", "nil\ntrue\nfalse\n"},
+		{"print 1; print 2.5; print -0; print 100000000;", "1\n2.5\n-0\n100000000\n"},
+		{"print 1000000000000 * 1000000000000; print 0.0000001; print 0.00000001;", "1e+24\n0.0000001\n1e-08\n"},
+		{"print 0/0; print 1/0; print -1/0;", "NaN\nInfinity\n-Infinity\n"},
+		{`print [1, 2.5, nil, "a", true];`, `[1, 2.5, nil, "a", true]` + "\n"},
+		{`print {"a": [1, "b"]};`, `{"a": [1, "b"]}` + "\n"},
+		{`print "plain";`, "plain\n"},
+	}
+	for _, test := range tests {
+		expectOutput(t, test.source, test.want)
+	}
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print str(3) + "x"; print str(nil) + str(true) + str([1, "a"]);`, "3x\nniltrue[1, \"a\"]\n"},
+		{`print num("  42 ") + 1; print num("1e3"); print num(".5"); print num(7);`, "43\n1000\n0.5\n7\n"},
+		{"print char(65); print char(233);", "A\né\n"},
+	}
+	for _, test := range tests {
+		expectOutput(t, test.source, test.want)
+	}
+}
+
+func TestConversionErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`num("abc");`, `Can't convert "abc" to a number`},
+		{`num("nan");`, `Can't convert "nan" to a number`},
+		{"num(nil);", "num expects a string but got nil"},
+		{"char(1.5);", "char expects a valid code point but got 1.5"},
+		{"char(-1);", "char expects a valid code point but got -1"},
+		{`char("A");`, `char expects a valid code point but got "A"`},
+		// Collections used to crash the error message.
+		{"char([1]);", "char expects a valid code point but got [1]"},
+		{`char({"a": 1});`, `char expects a valid code point but got {"a": 1}`},
+		{"var xs = []; xs.push(xs); char(xs);", "char expects a valid code point but got [[...]]"},
+	}
+	for _, test := range tests {
+		expectError(t, test.source, test.want)
+	}
+}
@@ -0,0 +1,609 @@
+package lox
+
+import (
+	"fmt"
+	"golox/lox/stmt"
+	"golox/lox/tok"
+)
+
+// proto is a compiled function: its bytecode, and the information needed
+// to create closures from it at runtime.
+type proto struct {
+	name         string
+	arity        int
+	upvalueCount int
+	chunk        chunk
+}
+
+func (p *proto) String() string {
+	if p.name == "" {
+		return "<script>"
+	}
+	return "<fn " + p.name + ">"
+}
+
+// upvalue is a variable captured by a closure. While the variable is still
+// live on the stack the upvalue refers to its slot; once the variable goes
+// out of scope its value is moved into the upvalue itself.
+type upvalue struct {
+	slot   int
+	isOpen bool
+	closed any
+}
+
+func (u *upvalue) get(vm *vm) any {
+	if u.isOpen {
+		return vm.stack[u.slot]
+	}
+	return u.closed
+}
+
+func (u *upvalue) set(vm *vm, value any) {
+	if u.isOpen {
+		vm.stack[u.slot] = value
+	} else {
+		u.closed = value
+	}
+}
+
+type Closure struct {
+	proto    *proto
+	upvalues []*upvalue
+	// globals holds the globals of the module the closure was created in.
+	globals *Globals
+}
+
+func (c *Closure) Arity() int {
+	return c.proto.arity
+}
+
+func (c *Closure) Call(in *Interpreter, arguments []any) (any, error) {
+	return in.vm.call(c, c, c.proto.name, arguments)
+}
+
+func (c *Closure) Bind(instance *Instance) Callable {
+	return &BoundMethod{receiver: instance, method: c}
+}
+
+func (c *Closure) String() string {
+	return c.proto.String()
+}
+
+type BoundMethod struct {
+	receiver *Instance
+	method   *Closure
+}
+
+func (b *BoundMethod) Arity() int {
+	return b.method.Arity()
+}
+
+func (b *BoundMethod) Call(in *Interpreter, arguments []any) (any, error) {
+	return in.vm.call(b.method, b.receiver, b.method.proto.name, arguments)
+}
+
+func (b *BoundMethod) String() string {
+	return b.method.String()
+}
+
+type vmFrame struct {
+	closure *Closure
+	name    string
+	ip      int
+	slots   int
+}
+
+// handler is an exception handler installed by a try statement. When a
+// value is thrown, the stack is truncated to stack entries and execution
+// resumes at ip in the given frame.
+type handler struct {
+	frame int
+	ip    int
+	stack int
+}
+
+type vm struct {
+	in           *Interpreter
+	stack        []any
+	frames       []vmFrame
+	handlers     []handler
+	openUpvalues []*upvalue
+}
+
+func newVM(in *Interpreter) *vm {
+	return &vm{
+		in:    in,
+		stack: make([]any, 0, 256),
+	}
+}
+
+func (vm *vm) interpret(p *proto) error {
+	closure := &Closure{proto: p, globals: vm.in.globals}
+	_, err := vm.call(closure, closure, "", nil)
+	return err
+}
+
+// call runs closure to completion with the given receiver in slot zero. It
+// can be entered recursively, for example when a native function calls
+// back into Lox code.
+func (vm *vm) call(closure *Closure, receiver any, name string, arguments []any) (any, error) {
+	base := len(vm.frames)
+	vm.push(receiver)
+	for _, a := range arguments {
+		vm.push(a)
+	}
+	vm.frames = append(vm.frames, vmFrame{
+		closure: closure,
+		name:    name,
+		slots:   len(vm.stack) - len(arguments) - 1,
+	})
+	return vm.run(base)
+}
+
+func (vm *vm) push(value any) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *vm) pop() any {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *vm) peek(distance int) any {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// run executes instructions until the frame at index base returns. If an
+// error is thrown, it resumes at the innermost handler installed by a try
+// statement since base, or else discards the frames entered since base.
+func (vm *vm) run(base int) (any, error) {
+	for {
+		result, err := vm.execute(base)
+		if err == nil {
+			return result, nil
+		}
+		if !vm.catch(base, err) {
+			return nil, vm.unwind(base, err)
+		}
+	}
+}
+
+func (vm *vm) execute(base int) (any, error) {
+	for {
+		frame := &vm.frames[len(vm.frames)-1]
+		chunk := &frame.closure.proto.chunk
+		code := chunk.code
+		start := frame.ip
+		op := opCode(code[start])
+		frame.ip++
+
+		switch op {
+		case opConstant:
+			vm.push(chunk.constants[vm.readShort(frame)])
+		case opNil:
+			vm.push(nil)
+		case opTrue:
+			vm.push(true)
+		case opFalse:
+			vm.push(false)
+		case opPop:
+			vm.pop()
+		case opGetLocal:
+			vm.push(vm.stack[frame.slots+vm.readByte(frame)])
+		case opSetLocal:
+			vm.stack[frame.slots+vm.readByte(frame)] = vm.peek(0)
+		case opGetGlobal:
+			name := chunk.constants[vm.readShort(frame)].(string)
+			value, ok := frame.closure.globals.Lookup(name)
+			if !ok {
+				return nil, vm.runtimeError(chunk.token(start),
+					"Undefined variable '"+name+"'")
+			}
+			vm.push(value)
+		case opDefineGlobal:
+			name := chunk.constants[vm.readShort(frame)].(string)
+			frame.closure.globals.Define(name, vm.pop())
+		case opSetGlobal:
+			name := chunk.constants[vm.readShort(frame)].(string)
+			if !frame.closure.globals.set(name, vm.peek(0)) {
+				return nil, vm.runtimeError(chunk.token(start),
+					"Undefined variable '"+name+"'")
+			}
+		case opGetUpvalue:
+			vm.push(frame.closure.upvalues[vm.readByte(frame)].get(vm))
+		case opSetUpvalue:
+			frame.closure.upvalues[vm.readByte(frame)].set(vm, vm.peek(0))
+		case opGetProperty:
+			vm.readShort(frame)
+			value, err := getProperty(vm.peek(0), chunk.token(start))
+			if err != nil {
+				return nil, err
+			}
+			vm.pop()
+			vm.push(value)
+		case opSetProperty:
+			vm.readShort(frame)
+			value := vm.peek(0)
+			if err := setProperty(vm.peek(1), chunk.token(start), value); err != nil {
+				return nil, err
+			}
+			vm.pop()
+			vm.pop()
+			vm.push(value)
+		case opGetSuper:
+			name := chunk.constants[vm.readShort(frame)].(string)
+			superclass := vm.pop().(*Class)
+			receiver := vm.pop().(*Instance)
+			method := superclass.FindMethod(name)
+			if method == nil {
+				return nil, vm.runtimeError(chunk.token(start),
+					"Undefined property '"+name+"'")
+			}
+			vm.push(method.Bind(receiver))
+		case opList:
+			count := vm.readShort(frame)
+			elements := make([]any, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(NewList(elements))
+		case opMap:
+			count := vm.readShort(frame)
+			m := NewMap()
+			entries := vm.stack[len(vm.stack)-2*count:]
+			for i := 0; i < len(entries); i += 2 {
+				if err := m.Put(entries[i], entries[i+1]); err != nil {
+					return nil, nativeError(err, chunk.token(start))
+				}
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*count]
+			vm.push(m)
+		case opGetIndex:
+			value, err := getIndex(vm.peek(1), vm.peek(0), chunk.token(start))
+			if err != nil {
+				return nil, err
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.push(value)
+		case opSetIndex:
+			value := vm.peek(0)
+			if err := setIndex(vm.peek(2), vm.peek(1), chunk.token(start), value); err != nil {
+				return nil, err
+			}
+			vm.stack = vm.stack[:len(vm.stack)-3]
+			vm.push(value)
+		case opEqual:
+			b, a := vm.pop(), vm.pop()
+			vm.push(isEqual(a, b))
+		case opNotEqual:
+			b, a := vm.pop(), vm.pop()
+			vm.push(!isEqual(a, b))
+		case opGreater, opGreaterEqual, opLess, opLessEqual,
+			opSubtract, opMultiply, opDivide:
+			b, a := vm.pop(), vm.pop()
+			if err := checkNumberOperands(chunk.token(start), a, b); err != nil {
+				return nil, err
+			}
+			vm.push(arithmetic(op, a.(float64), b.(float64)))
+		case opModulo:
+			b, a := vm.pop(), vm.pop()
+			if err := checkNumberOperands(chunk.token(start), a, b); err != nil {
+				return nil, err
+			}
+			result, err := modulo(chunk.token(start), a.(float64), b.(float64))
+			if err != nil {
+				return nil, err
+			}
+			vm.push(result)
+		case opAdd:
+			b, a := vm.pop(), vm.pop()
+			if isNumber(a) && isNumber(b) {
+				vm.push(a.(float64) + b.(float64))
+			} else if isString(a) && isString(b) {
+				vm.push(a.(string) + b.(string))
+			} else {
+				return nil, vm.runtimeError(chunk.token(start),
+					"operands should be numbers or strings")
+			}
+		case opNot:
+			vm.push(!isTruthy(vm.pop()))
+		case opNegate:
+			value := vm.pop()
+			if err := checkNumberOperand(chunk.token(start), value); err != nil {
+				return nil, err
+			}
+			vm.push(-value.(float64))
+		case opPrint:
+			fmt.Fprintln(vm.in.stdout, stringify(vm.pop()))
+		case opJump:
+			offset := vm.readShort(frame)
+			frame.ip += offset
+		case opJumpIfFalse:
+			offset := vm.readShort(frame)
+			if !isTruthy(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case opLoop:
+			offset := vm.readShort(frame)
+			frame.ip -= offset
+		case opCall:
+			argCount := vm.readByte(frame)
+			err := vm.callValue(vm.peek(argCount), argCount, chunk.token(start+1))
+			if err != nil {
+				return nil, err
+			}
+		case opInvoke:
+			name := chunk.constants[vm.readShort(frame)].(string)
+			argCount := vm.readByte(frame)
+			err := vm.invoke(name, argCount, chunk.token(start), chunk.token(start+3))
+			if err != nil {
+				return nil, err
+			}
+		case opClosure:
+			p := chunk.constants[vm.readShort(frame)].(*proto)
+			closure := &Closure{
+				proto:    p,
+				upvalues: make([]*upvalue, p.upvalueCount),
+				globals:  frame.closure.globals,
+			}
+			for i := range closure.upvalues {
+				isLocal := vm.readByte(frame) == 1
+				index := vm.readByte(frame)
+				if isLocal {
+					closure.upvalues[i] = vm.captureUpvalue(frame.slots + index)
+				} else {
+					closure.upvalues[i] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case opCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case opReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.slots)
+			vm.stack = vm.stack[:frame.slots]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == base {
+				return result, nil
+			}
+			vm.push(result)
+		case opTry:
+			offset := vm.readShort(frame)
+			vm.handlers = append(vm.handlers, handler{
+				frame: len(vm.frames) - 1,
+				ip:    frame.ip + offset,
+				stack: len(vm.stack),
+			})
+		case opEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case opThrow:
+			value := vm.pop()
+			if err, ok := value.(*Error); ok {
+				// Rethrow an error after running a finally block.
+				return nil, err
+			}
+			return nil, throw(value, chunk.token(start))
+		case opCatch:
+			vm.push(caughtValue(vm.pop().(*Error)))
+		case opImport:
+			s := chunk.constants[vm.readShort(frame)].(*stmt.Import)
+			module, err := vm.in.importModule(s)
+			if err != nil {
+				return nil, err
+			}
+			vm.push(module)
+		case opClass:
+			name := chunk.constants[vm.readShort(frame)].(string)
+			vm.push(NewClass(name, nil, make(map[string]Method)))
+		case opInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				return nil, vm.runtimeError(chunk.token(start),
+					"Superclass must be a class")
+			}
+			vm.peek(0).(*Class).superclass = superclass
+			vm.pop()
+		case opMethod:
+			name := chunk.constants[vm.readShort(frame)].(string)
+			class := vm.peek(1).(*Class)
+			class.methods[name] = vm.pop().(*Closure)
+		default:
+			return nil, vm.runtimeError(chunk.token(start),
+				fmt.Sprintf("unknown instruction %s", op))
+		}
+	}
+}
+
+func (vm *vm) readByte(frame *vmFrame) int {
+	b := frame.closure.proto.chunk.code[frame.ip]
+	frame.ip++
+	return int(b)
+}
+
+func (vm *vm) readShort(frame *vmFrame) int {
+	s := frame.closure.proto.chunk.readShort(frame.ip)
+	frame.ip += 2
+	return s
+}
+
+func arithmetic(op opCode, a float64, b float64) any {
+	switch op {
+	case opGreater:
+		return a > b
+	case opGreaterEqual:
+		return a >= b
+	case opLess:
+		return a < b
+	case opLessEqual:
+		return a <= b
+	case opSubtract:
+		return a - b
+	case opMultiply:
+		return a * b
+	default:
+		return a / b
+	}
+}
+
+func (vm *vm) callValue(callee any, argCount int, paren *tok.Token) error {
+	switch c := callee.(type) {
+	case *Closure:
+		return vm.callClosure(c, c.proto.name, argCount, paren)
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = c.receiver
+		return vm.callClosure(c.method, c.method.proto.name, argCount, paren)
+	case *Class:
+		if initializer, ok := c.FindMethod("init").(*Closure); ok {
+			vm.stack[len(vm.stack)-argCount-1] = NewInstance(c)
+			return vm.callClosure(initializer, c.name, argCount, paren)
+		}
+	}
+
+	f, ok := callee.(Callable)
+	if !ok {
+		return &Error{
+			Token:   paren,
+			Message: "Can only call functions and classes",
+		}
+	}
+	if err := checkArity(f.Arity(), argCount, paren); err != nil {
+		return err
+	}
+
+	arguments := make([]any, argCount)
+	copy(arguments, vm.stack[len(vm.stack)-argCount:])
+	vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+	result, err := f.Call(vm.in, arguments)
+	if err != nil {
+		return nativeError(err, paren)
+	}
+	vm.push(result)
+	return nil
+}
+
+func (vm *vm) callClosure(closure *Closure, name string, argCount int, paren *tok.Token) error {
+	if err := checkArity(closure.proto.arity, argCount, paren); err != nil {
+		return err
+	}
+	if len(vm.frames) == maxFrames {
+		return &Error{Token: paren, Message: "Stack overflow"}
+	}
+	vm.frames = append(vm.frames, vmFrame{
+		closure: closure,
+		name:    name,
+		slots:   len(vm.stack) - argCount - 1,
+	})
+	return nil
+}
+
+func (vm *vm) invoke(name string, argCount int, nameToken *tok.Token, paren *tok.Token) error {
+	receiver := vm.peek(argCount)
+	if instance, ok := receiver.(*Instance); ok {
+		if _, ok := instance.fields[name]; !ok {
+			method := instance.class.FindMethod(name)
+			if closure, ok := method.(*Closure); ok {
+				return vm.callClosure(closure, name, argCount, paren)
+			}
+		}
+	}
+
+	value, err := getProperty(receiver, nameToken)
+	if err != nil {
+		return err
+	}
+	vm.stack[len(vm.stack)-argCount-1] = value
+	return vm.callValue(value, argCount, paren)
+}
+
+func (vm *vm) captureUpvalue(slot int) *upvalue {
+	i := len(vm.openUpvalues) - 1
+	for ; i >= 0 && vm.openUpvalues[i].slot >= slot; i-- {
+		if vm.openUpvalues[i].slot == slot {
+			return vm.openUpvalues[i]
+		}
+	}
+
+	created := &upvalue{slot: slot, isOpen: true}
+	vm.openUpvalues = append(vm.openUpvalues, nil)
+	copy(vm.openUpvalues[i+2:], vm.openUpvalues[i+1:])
+	vm.openUpvalues[i+1] = created
+	return created
+}
+
+// closeUpvalues closes every open upvalue that refers to a stack slot at or
+// above last.
+func (vm *vm) closeUpvalues(last int) {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= last {
+		u := vm.openUpvalues[i-1]
+		u.closed = vm.stack[u.slot]
+		u.isOpen = false
+		i--
+	}
+	vm.openUpvalues = vm.openUpvalues[:i]
+}
+
+func (vm *vm) runtimeError(token *tok.Token, message string) error {
+	return &Error{Token: token, Message: message}
+}
+
+// catch transfers control to the innermost handler installed since base,
+// with the error on top of the stack. It reports whether there was such a
+// handler.
+func (vm *vm) catch(base int, err error) bool {
+	runtimeError, ok := err.(*Error)
+	if !ok || len(vm.handlers) == 0 || vm.handlers[len(vm.handlers)-1].frame < base {
+		return false
+	}
+
+	// Attach the traceback now in case a finally block rethrows the error.
+	if runtimeError.Trace == nil {
+		runtimeError.Trace = vm.stackTrace(runtimeError)
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.frames = vm.frames[:h.frame+1]
+	vm.closeUpvalues(h.stack)
+	vm.stack = vm.stack[:h.stack]
+	vm.push(runtimeError)
+	vm.frames[h.frame].ip = h.ip
+	return true
+}
+
+// unwind discards the frames entered since base, attaching a traceback to
+// err if it doesn't already have one.
+func (vm *vm) unwind(base int, err error) error {
+	if runtimeError, ok := err.(*Error); ok && runtimeError.Trace == nil {
+		runtimeError.Trace = vm.stackTrace(runtimeError)
+	}
+	slots := vm.frames[base].slots
+	vm.closeUpvalues(slots)
+	vm.stack = vm.stack[:slots]
+	vm.frames = vm.frames[:base]
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frame >= base {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+	return err
+}
+
+func (vm *vm) stackTrace(err *Error) []StackFrame {
+	if len(vm.frames) == 1 && vm.frames[0].name == "" {
+		// Like the tree walker, leave errors in top-level code without a
+		// traceback.
+		return nil
+	}
+
+	var trace []StackFrame
+	span := err.Span()
+	for i := len(vm.frames) - 1; i >= 0; i-- {
+		frame := &vm.frames[i]
+		trace = append(trace, StackFrame{Function: frame.name, Span: span})
+		if i > 0 {
+			caller := &vm.frames[i-1]
+			span = tokenSpan(caller.closure.proto.chunk.token(caller.ip - 1))
+		}
+	}
+	return trace
+}
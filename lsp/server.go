@@ -0,0 +1,398 @@
+// Package lsp implements a Language Server Protocol server for Lox.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"golox/internal/wire"
+	"golox/lox"
+	"golox/lox/stmt"
+	"golox/lox/tok"
+	"io"
+	"net/url"
+	"strings"
+)
+
+var errExitWithoutShutdown = errors.New("exit notification before shutdown request")
+
+type document struct {
+	uri      string
+	lines    []string
+	analysis *lox.Analysis
+}
+
+type server struct {
+	r        *bufio.Reader
+	w        io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+// Serve runs a language server that reads messages from r and writes them
+// to w, until the client sends the exit notification.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{
+		r:    bufio.NewReader(r),
+		w:    w,
+		docs: make(map[string]*document),
+	}
+	for {
+		body, err := wire.ReadMessage(s.r)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			// The request's ID can't be known, so the error has none.
+			if err := s.fail(nil, codeParseError, "Parse error: "+err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+		if err := s.handle(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(msg *message) error {
+	// Messages without an ID are notifications, which get no response.
+	if msg.ID == nil {
+		switch msg.Method {
+		case "textDocument/didOpen":
+			var params DidOpenTextDocumentParams
+			if json.Unmarshal(msg.Params, &params) == nil {
+				return s.update(params.TextDocument.URI, params.TextDocument.Text)
+			}
+		case "textDocument/didChange":
+			var params DidChangeTextDocumentParams
+			if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+				// The server asks for full text sync, so the last change
+				// is the whole document.
+				text := params.ContentChanges[len(params.ContentChanges)-1].Text
+				return s.update(params.TextDocument.URI, text)
+			}
+		case "textDocument/didClose":
+			var params DidCloseTextDocumentParams
+			if json.Unmarshal(msg.Params, &params) == nil {
+				delete(s.docs, params.TextDocument.URI)
+				return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+					URI:         params.TextDocument.URI,
+					Diagnostics: []Diagnostic{},
+				})
+			}
+		}
+		return nil
+	}
+
+	if s.shutdown && msg.Method != "shutdown" {
+		return s.fail(msg.ID, codeInvalidRequest, "Server is shutting down")
+	}
+
+	var result any
+	var err error
+	switch msg.Method {
+	case "initialize":
+		result = map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "golox"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.definition(params)
+		}
+	case "textDocument/references":
+		var params ReferenceParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.references(params)
+		}
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.hover(params)
+		}
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.documentSymbols(params)
+		}
+	default:
+		return s.fail(msg.ID, codeMethodNotFound, "Unknown method "+msg.Method)
+	}
+	if err != nil {
+		return s.fail(msg.ID, codeInvalidParams, err.Error())
+	}
+	return wire.WriteMessage(s.w, map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+}
+
+func (s *server) fail(id json.RawMessage, code int, message string) error {
+	return wire.WriteMessage(s.w, map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   responseError{Code: code, Message: message},
+	})
+}
+
+func (s *server) notify(method string, params any) error {
+	return wire.WriteMessage(s.w, map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// update analyzes a new version of a document and publishes its
+// diagnostics.
+func (s *server) update(uri string, text string) error {
+	doc := &document{
+		uri:      uri,
+		lines:    strings.Split(text, "\n"),
+		analysis: lox.Analyze(uriPath(uri), text),
+	}
+	s.docs[uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, d := range doc.analysis.Diagnostics {
+		severity := severityError
+		if d.Severity == lox.SeverityWarning {
+			severity = severityWarning
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    doc.spanRange(d.Span),
+			Severity: severity,
+			Code:     string(d.Code),
+			Source:   "golox",
+			Message:  d.Message,
+		})
+	}
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+func (s *server) definition(params TextDocumentPositionParams) any {
+	doc, symbol := s.symbolAt(params)
+	if symbol == nil {
+		return nil
+	}
+	return doc.location(symbol.Name)
+}
+
+func (s *server) references(params ReferenceParams) any {
+	doc, symbol := s.symbolAt(params.TextDocumentPositionParams)
+	if symbol == nil {
+		return nil
+	}
+	locations := []Location{}
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, doc.location(symbol.Name))
+	}
+	for _, ref := range symbol.References {
+		locations = append(locations, doc.location(ref.Token))
+	}
+	return locations
+}
+
+func (s *server) hover(params TextDocumentPositionParams) any {
+	doc, symbol := s.symbolAt(params)
+	if symbol == nil {
+		return nil
+	}
+	t := doc.tokenAt(symbol, params.Position)
+	return Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: "```lox\n" + signature(symbol) + "\n```\n" + description(symbol),
+		},
+		Range: doc.tokenRange(t),
+	}
+}
+
+func (s *server) documentSymbols(params DocumentSymbolParams) any {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbols := []DocumentSymbol{}
+	classes := make(map[*lox.Symbol]int)
+	for _, symbol := range doc.analysis.Symbols {
+		var kind int
+		switch {
+		case symbol.Kind == lox.SymbolMethod:
+			i, ok := classes[symbol.Container]
+			if ok {
+				symbols[i].Children = append(symbols[i].Children, doc.documentSymbol(symbol, symbolKindMethod))
+			}
+			continue
+		case !symbol.Global:
+			continue
+		case symbol.Kind == lox.SymbolClass:
+			kind = symbolKindClass
+			classes[symbol] = len(symbols)
+		case symbol.Kind == lox.SymbolFunction:
+			kind = symbolKindFunction
+		case symbol.Kind == lox.SymbolModule:
+			kind = symbolKindModule
+		default:
+			kind = symbolKindVariable
+		}
+		symbols = append(symbols, doc.documentSymbol(symbol, kind))
+	}
+	return symbols
+}
+
+// symbolAt finds the symbol declared or used at a position.
+func (s *server) symbolAt(params TextDocumentPositionParams) (*document, *lox.Symbol) {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	for _, symbol := range doc.analysis.Symbols {
+		if doc.tokenAt(symbol, params.Position) != nil {
+			return doc, symbol
+		}
+	}
+	return doc, nil
+}
+
+// tokenAt returns the declaration of symbol or the use of it that contains
+// pos, or nil if there is none.
+func (d *document) tokenAt(symbol *lox.Symbol, pos Position) *tok.Token {
+	if d.contains(symbol.Name, pos) {
+		return symbol.Name
+	}
+	for _, ref := range symbol.References {
+		if d.contains(ref.Token, pos) {
+			return ref.Token
+		}
+	}
+	return nil
+}
+
+func (d *document) contains(t *tok.Token, pos Position) bool {
+	r := d.tokenRange(t)
+	return r.Start.Line == pos.Line && r.Start.Character <= pos.Character &&
+		pos.Character <= r.End.Character
+}
+
+func (d *document) documentSymbol(symbol *lox.Symbol, kind int) DocumentSymbol {
+	r := d.tokenRange(symbol.Name)
+	return DocumentSymbol{
+		Name:           symbol.Name.Lexeme,
+		Detail:         signature(symbol),
+		Kind:           kind,
+		Range:          r,
+		SelectionRange: r,
+	}
+}
+
+func (d *document) location(t *tok.Token) Location {
+	return Location{URI: d.uri, Range: d.tokenRange(t)}
+}
+
+func (d *document) tokenRange(t *tok.Token) Range {
+	return Range{
+		Start: d.position(t.Line, t.Column),
+		End:   d.position(t.EndLine, t.EndColumn),
+	}
+}
+
+func (d *document) spanRange(span lox.Span) Range {
+	if span.Column == 0 {
+		// Underline the whole line.
+		start := d.position(span.Line, 1)
+		return Range{Start: start, End: d.position(span.Line, len(d.line(span.Line))+1)}
+	}
+	start := d.position(span.Line, span.Column)
+	end := d.position(span.Line, span.Column+span.Length)
+	return Range{Start: start, End: end}
+}
+
+// position converts a 1-based line and byte column to an LSP position,
+// which counts UTF-16 code units.
+func (d *document) position(line int, column int) Position {
+	text := d.line(line)
+	if column-1 > len(text) {
+		column = len(text) + 1
+	}
+	character := 0
+	for _, r := range text[:column-1] {
+		if r >= 0x10000 {
+			// A surrogate pair.
+			character += 2
+		} else {
+			character++
+		}
+	}
+	return Position{Line: line - 1, Character: character}
+}
+
+func (d *document) line(line int) string {
+	if line < 1 || line > len(d.lines) {
+		return ""
+	}
+	return strings.TrimSuffix(d.lines[line-1], "\r")
+}
+
+// signature shows how a symbol is declared.
+func signature(symbol *lox.Symbol) string {
+	name := symbol.Name.Lexeme
+	switch s := symbol.Declaration.(type) {
+	case *stmt.Function:
+		params := make([]string, len(s.Params))
+		for i, param := range s.Params {
+			params[i] = param.Lexeme
+		}
+		if symbol.Kind == lox.SymbolMethod {
+			return symbol.Container.Name.Lexeme + "." + name + "(" + strings.Join(params, ", ") + ")"
+		}
+		return "fun " + name + "(" + strings.Join(params, ", ") + ")"
+	case *stmt.Class:
+		if s.Superclass != nil {
+			return "class " + name + " < " + s.Superclass.Name.Lexeme
+		}
+		return "class " + name
+	case *stmt.Var:
+		return "var " + name
+	case *stmt.Import:
+		return "import " + s.Path.Lexeme + " as " + name
+	}
+	return name
+}
+
+func description(symbol *lox.Symbol) string {
+	switch symbol.Kind {
+	case lox.SymbolVariable, lox.SymbolFunction, lox.SymbolClass:
+		if symbol.Global {
+			return "global " + symbol.Kind.String()
+		}
+		return "local " + symbol.Kind.String()
+	}
+	return symbol.Kind.String()
+}
+
+// uriPath returns the file path of a file URI, or the URI itself if it
+// isn't one.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
@@ -0,0 +1,281 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"golox/internal/wire"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// reply is a response or notification sent by the server.
+type reply struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// serve sends messages to a new server, followed by shutdown and exit, and
+// returns everything the server sent back except the shutdown response.
+func serve(t *testing.T, messages ...any) []reply {
+	t.Helper()
+	var in bytes.Buffer
+	messages = append(messages,
+		map[string]any{"jsonrpc": "2.0", "id": 999, "method": "shutdown"},
+		map[string]any{"jsonrpc": "2.0", "method": "exit"})
+	for _, msg := range messages {
+		if raw, ok := msg.(string); ok {
+			in.WriteString("Content-Length: " + strconv.Itoa(len(raw)) + "\r\n\r\n" + raw)
+			continue
+		}
+		if err := wire.WriteMessage(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	if err := Serve(&in, &out); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+
+	var replies []reply
+	r := bufio.NewReader(&out)
+	for {
+		body, err := wire.ReadMessage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rep reply
+		if err := json.Unmarshal(body, &rep); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, rep)
+	}
+	if len(replies) == 0 || string(replies[len(replies)-1].ID) != "999" {
+		t.Fatalf("no shutdown response in %v", replies)
+	}
+	return replies[:len(replies)-1]
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notification(method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+const testURI = "file:///tmp/test.lox"
+
+const testSource = `class A < B {
+  get(x) { var y = x; return y; }
+}
+class B {}
+fun f(a) { return a + g; }
+var g = f(1);
+g = 2;
+`
+
+func open(text string) map[string]any {
+	return notification("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": testURI, "languageId": "lox", "version": 1, "text": text},
+	})
+}
+
+func at(line int, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": testURI},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+func span(line int, start int, end int) Range {
+	return Range{Start: Position{line, start}, End: Position{line, end}}
+}
+
+func decode[T any](t *testing.T, data json.RawMessage) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("decoding %s: %v", data, err)
+	}
+	return v
+}
+
+func TestInitialize(t *testing.T) {
+	replies := serve(t, request(1, "initialize", map[string]any{}))
+	if len(replies) != 1 {
+		t.Fatalf("got %d replies, want 1", len(replies))
+	}
+	result := decode[struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}](t, replies[0].Result)
+	for _, name := range []string{"definitionProvider", "referencesProvider", "hoverProvider", "documentSymbolProvider"} {
+		if result.Capabilities[name] != true {
+			t.Errorf("capability %s = %v, want true", name, result.Capabilities[name])
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	change := func(text string) map[string]any {
+		return notification("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": testURI, "version": 2},
+			"contentChanges": []map[string]any{{"text": text}},
+		})
+	}
+	replies := serve(t, open(testSource), change("var x = ;"), change("return 1;"),
+		notification("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": testURI}}))
+
+	want := [][]Diagnostic{
+		{},
+		{{Range: span(0, 8, 9), Severity: severityError, Code: "expected-expression", Source: "golox", Message: "Expect expression."}},
+		{{Range: span(0, 0, 6), Severity: severityError, Code: "top-level-return", Source: "golox", Message: "Can't return from top-level code"}},
+		{},
+	}
+	if len(replies) != len(want) {
+		t.Fatalf("got %d replies, want %d", len(replies), len(want))
+	}
+	for i, r := range replies {
+		if r.Method != "textDocument/publishDiagnostics" {
+			t.Errorf("reply %d is %q, want diagnostics", i, r.Method)
+			continue
+		}
+		params := decode[PublishDiagnosticsParams](t, r.Params)
+		if params.URI != testURI || !reflect.DeepEqual(params.Diagnostics, want[i]) {
+			t.Errorf("diagnostics %d = %+v, want %+v", i, params.Diagnostics, want[i])
+		}
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	references := at(5, 4)
+	references["context"] = map[string]any{"includeDeclaration": true}
+	replies := serve(t, open(testSource),
+		request(1, "textDocument/definition", at(4, 22)),
+		request(2, "textDocument/references", references),
+		request(3, "textDocument/definition", at(4, 12)))
+
+	definition := decode[Location](t, replies[1].Result)
+	if want := (Location{URI: testURI, Range: span(5, 4, 5)}); definition != want {
+		t.Errorf("definition = %+v, want %+v", definition, want)
+	}
+
+	locations := decode[[]Location](t, replies[2].Result)
+	want := []Location{
+		{URI: testURI, Range: span(5, 4, 5)},
+		{URI: testURI, Range: span(4, 22, 23)},
+		{URI: testURI, Range: span(6, 0, 1)},
+	}
+	if !reflect.DeepEqual(locations, want) {
+		t.Errorf("references = %+v, want %+v", locations, want)
+	}
+
+	// "return" isn't a name.
+	if string(replies[3].Result) != "null" {
+		t.Errorf("definition of a keyword = %s, want null", replies[3].Result)
+	}
+}
+
+func TestHover(t *testing.T) {
+	replies := serve(t, open(testSource),
+		request(1, "textDocument/hover", at(1, 5)),
+		request(2, "textDocument/hover", at(1, 29)))
+
+	tests := []struct {
+		value string
+		rng   Range
+	}{
+		{"```lox\nA.get(x)\n```\nmethod", span(1, 2, 5)},
+		{"```lox\nvar y\n```\nlocal variable", span(1, 29, 30)},
+	}
+	for i, test := range tests {
+		hover := decode[Hover](t, replies[i+1].Result)
+		if hover.Contents.Value != test.value || hover.Range != test.rng {
+			t.Errorf("hover %d = %+v, want %q at %+v", i+1, hover, test.value, test.rng)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	replies := serve(t, open(testSource),
+		request(1, "textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": testURI}}))
+	symbols := decode[[]DocumentSymbol](t, replies[1].Result)
+
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Detail)
+		for _, c := range s.Children {
+			names = append(names, c.Detail)
+		}
+	}
+	want := []string{"class A < B", "A.get(x)", "class B", "fun f(a)", "var g"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("symbols = %q, want %q", names, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	replies := serve(t,
+		request(1, "unknown/method", nil),
+		request(2, "textDocument/hover", "not an object"),
+		"{not json",
+		request(3, "initialize", map[string]any{}))
+
+	want := []struct {
+		id   string
+		code int
+	}{
+		{"1", codeMethodNotFound},
+		{"2", codeInvalidParams},
+		{"null", codeParseError},
+	}
+	if len(replies) != 4 {
+		t.Fatalf("got %d replies, want 4", len(replies))
+	}
+	for i, w := range want {
+		r := replies[i]
+		if string(r.ID) != w.id || r.Error == nil || r.Error.Code != w.code {
+			t.Errorf("reply %d = id %s, error %+v; want id %s, code %d", i, r.ID, r.Error, w.id, w.code)
+		}
+	}
+	// The server keeps serving after a malformed message.
+	if string(replies[3].ID) != "3" || replies[3].Error != nil {
+		t.Errorf("initialize after a parse error got %+v", replies[3])
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	var in, out bytes.Buffer
+	wire.WriteMessage(&in, notification("exit", nil))
+	if err := Serve(&in, &out); err != errExitWithoutShutdown {
+		t.Errorf("Serve = %v, want %v", err, errExitWithoutShutdown)
+	}
+}
+
+func TestRequestsAfterShutdown(t *testing.T) {
+	var in, out bytes.Buffer
+	wire.WriteMessage(&in, request(1, "shutdown", nil))
+	wire.WriteMessage(&in, request(2, "textDocument/hover", at(0, 0)))
+	wire.WriteMessage(&in, notification("exit", nil))
+	if err := Serve(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(&out)
+	wire.ReadMessage(r)
+	body, err := wire.ReadMessage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := decode[reply](t, body)
+	if rep.Error == nil || rep.Error.Code != codeInvalidRequest {
+		t.Errorf("request after shutdown got %s", body)
+	}
+}
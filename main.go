@@ -1,21 +1,84 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"golox/lox"
+	"golox/lsp"
 	"os"
 )
 
 func main() {
-	if len(os.Args) > 2 {
-		fmt.Printf("Usage: golox [script]\n")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			runFmt(os.Args[2:])
+			return
+		case "lint":
+			runLint(os.Args[2:])
+			return
+		case "debug":
+			runDebug(os.Args[2:])
+			return
+		case "lsp":
+			if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	useVM := flag.Bool("vm", false, "run programs on the bytecode virtual machine")
+	dumpAST := flag.Bool("dump-ast", false, "print the syntax tree of the script instead of running it")
+	profile := flag.Bool("profile", false, "print a profile of the script to standard error after running it")
+	profileOut := flag.String("profile-out", "", "write a profile of the script to `file` as folded stacks")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: golox [--vm] [--dump-ast] [script]\n")
+		fmt.Fprintf(os.Stderr, "       golox [--profile] [--profile-out file] script\n")
+		fmt.Fprintf(os.Stderr, "       golox fmt [-w] [--check] [file ...]\n")
+		fmt.Fprintf(os.Stderr, "       golox lint file ...\n")
+		fmt.Fprintf(os.Stderr, "       golox lsp\n")
+		fmt.Fprintf(os.Stderr, "       golox debug script\n")
+		fmt.Fprintf(os.Stderr, "       golox debug --dap\n")
+	}
+	flag.Parse()
+
+	var options []lox.Option
+	if *useVM {
+		options = append(options, lox.WithBackend(lox.BackendVM))
+	}
+
+	if flag.NArg() > 1 {
+		flag.Usage()
 		os.Exit(64)
-	} else if len(os.Args) == 2 {
-		if err := lox.RunFile(os.Args[1]); err != nil {
-			fmt.Printf("Error: %s\n", err)
+	} else if *dumpAST {
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(64)
+		}
+		in := lox.NewInterpreter(options...)
+		if err := in.DumpAST(flag.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(in.ExitCode())
+	} else if *profile || *profileOut != "" {
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(64)
+		}
+		if *useVM {
+			fmt.Fprintf(os.Stderr, "Error: the profiler needs the tree-walking interpreter\n")
+			os.Exit(64)
+		}
+		runProfile(flag.Arg(0), *profile, *profileOut)
+	} else if flag.NArg() == 1 {
+		if err := lox.NewInterpreter(options...).RunFile(flag.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 			os.Exit(1)
 		}
 	} else {
-		lox.RunPrompt()
+		lox.NewInterpreter(options...).RunPrompt()
 	}
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"golox/lox"
+	"os"
+)
+
+// runProfile runs a script with the profiler, printing the report to
+// standard error if report is set and writing folded stacks to out if it
+// isn't empty. It exits with the script's status.
+func runProfile(path string, report bool, out string) {
+	profiler := lox.NewProfiler()
+	in := lox.NewInterpreter(lox.WithProfiler(profiler))
+	if err := in.Run(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	profiler.Stop()
+
+	if report {
+		profiler.WriteReport(os.Stderr)
+	}
+	if out != "" {
+		if err := writeFolded(profiler, out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(in.ExitCode())
+}
+
+func writeFolded(profiler *lox.Profiler, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := profiler.WriteFolded(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}